@@ -4,12 +4,15 @@ go 1.19
 
 require (
 	cloud.google.com/go/storage v1.29.0
+	github.com/andybalholm/brotli v1.0.5
 	github.com/coreos/go-oidc/v3 v3.5.0
 	github.com/go-chi/chi/v5 v5.0.8
 	github.com/golang-jwt/jwt/v4 v4.5.0
 	github.com/kelseyhightower/envconfig v1.4.0
+	github.com/klauspost/compress v1.16.7
 	github.com/stretchr/testify v1.8.1
 	golang.org/x/oauth2 v0.3.0
+	google.golang.org/api v0.106.0
 )
 
 require (
@@ -32,7 +35,6 @@ require (
 	golang.org/x/sys v0.3.0 // indirect
 	golang.org/x/text v0.5.0 // indirect
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
-	google.golang.org/api v0.106.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/genproto v0.0.0-20230110181048-76db0878b65f // indirect
 	google.golang.org/grpc v1.51.0 // indirect
@@ -7,24 +7,61 @@ import (
 )
 
 type Config struct {
-	Listen                   string   `envconfig:"listen" default:""`
-	Port                     string   `envconfig:"port" default:"8080"`
-	GoogleCloudStorageBucket string   `envconfig:"google_cloud_storage_bucket"`
-	MainPageSuffix           string   `envconfig:"main_page_suffix" default:"index.html"`
-	NotFoundPage             string   `envconfig:"not_found_page" default:""`
-	BaseURL                  string   `envconfig:"base_url" default:""`
-	AuthType                 string   `envconfig:"auth_type" default:"none"`
-	OIDCProvider             string   `envconfig:"oidc_provider" default:"https://accounts.google.com"`
-	OIDCScopes               []string `envconfig:"oidc_scopes" default:"openid,profile,email"`
-	OIDCAuthorizeURL         string   `envconfig:"oidc_authorize_url" default:""`
-	OIDCTokenURL             string   `envconfig:"oidc_token_url" default:""`
-	OIDCClientID             string   `envconfig:"oidc_client_id" default:""`
-	OIDCClientSecret         string   `envconfig:"oidc_client_secret" default:""`
-	OIDCGoogleHostedDomain   string   `envconfig:"oidc_google_hosted_domain" default:""`
-	JWTExpiration            int64    `envconfig:"jwt_expiration" default:"3600"`
-	JWTSecret                string   `envconfig:"jwt_secret"`
-	BasicAuthUser            string   `envconfig:"basic_auth_user" default:""`
-	BasicAuthPassword        string   `envconfig:"basic_auth_password" default:""`
+	Listen                   string         `envconfig:"listen" default:""`
+	Port                     string         `envconfig:"port" default:"8080"`
+	GoogleCloudStorageBucket string         `envconfig:"google_cloud_storage_bucket"`
+	MainPageSuffix           string         `envconfig:"main_page_suffix" default:"index.html"`
+	NotFoundPage             string         `envconfig:"not_found_page" default:""`
+	SPAEntryDocument         string         `envconfig:"spa_entry_document" default:""`
+	VirtualHosts             VirtualHostMap `envconfig:"virtual_hosts" default:""`
+	Mounts                   MountMap       `envconfig:"mounts" default:""`
+	PrecompressedEncodings   []string       `envconfig:"precompressed_encodings" default:""`
+	PrecompressedCacheTTL    int64          `envconfig:"precompressed_cache_ttl" default:"300"`
+	CompressionEncodings     []string       `envconfig:"compression_encodings" default:""`
+	CompressionMIMETypes     []string       `envconfig:"compression_mime_types" default:"text/*,application/json,application/javascript,application/xml,application/manifest+json,application/wasm,image/svg+xml"`
+	CompressionMinSize       int64          `envconfig:"compression_min_size" default:"1024"`
+	ObjectCacheMaxMemory     int64          `envconfig:"object_cache_max_memory" default:"0"`
+	ObjectCacheTTL           int64          `envconfig:"object_cache_ttl" default:"60"`
+	ObjectCacheMaxObjectSize int64          `envconfig:"object_cache_max_object_size" default:"1048576"`
+	DiskCacheDir             string         `envconfig:"disk_cache_dir" default:""`
+	DiskCacheMaxSize         int64          `envconfig:"disk_cache_max_size" default:"10737418240"`
+	DiskCacheMinObjectSize   int64          `envconfig:"disk_cache_min_object_size" default:"1048576"`
+	MIMETypes                MIMETypeMap    `envconfig:"mime_types" default:""`
+	ContentTypeSniffing      bool           `envconfig:"content_type_sniffing" default:"false"`
+	MetadataHeaderPrefix     string         `envconfig:"metadata_header_prefix" default:"header-"`
+	MetadataHeaders          []string       `envconfig:"metadata_headers" default:"Content-Security-Policy,Link,Referrer-Policy,Permissions-Policy,X-Frame-Options,X-Robots-Tag"`
+	HeaderRules              HeaderRuleList `envconfig:"header_rules" default:""`
+	CORSAllowedOrigins       []string       `envconfig:"cors_allowed_origins" default:""`
+	CORSAllowedMethods       []string       `envconfig:"cors_allowed_methods" default:"GET,HEAD"`
+	CORSAllowedHeaders       []string       `envconfig:"cors_allowed_headers" default:"Range,If-Match,If-None-Match,If-Modified-Since,If-Range"`
+	CORSExposedHeaders       []string       `envconfig:"cors_exposed_headers" default:"Content-Length,Content-Range,ETag"`
+	CORSAllowCredentials     bool           `envconfig:"cors_allow_credentials" default:"false"`
+	CORSMaxAge               int            `envconfig:"cors_max_age" default:"600"`
+	CORSPathPrefixes         []string       `envconfig:"cors_path_prefixes" default:""`
+	RedirectsFile            string         `envconfig:"redirects_file" default:""`
+	RedirectsReloadInterval  int64          `envconfig:"redirects_reload_interval" default:"30"`
+	RedirectsCountryHeader   string         `envconfig:"redirects_country_header" default:"X-Client-Region"`
+	ErrorPages               ErrorPageMap   `envconfig:"error_pages" default:""`
+	ErrorPagesReloadInterval int64          `envconfig:"error_pages_reload_interval" default:"30"`
+	DirectoryListing         bool           `envconfig:"directory_listing" default:"false"`
+	DirectoryListingPageSize int            `envconfig:"directory_listing_page_size" default:"1000"`
+	JSONAPI                  bool           `envconfig:"json_api" default:"false"`
+	WritePathPrefixes        []string       `envconfig:"write_path_prefixes" default:""`
+	UploadMaxSize            int64          `envconfig:"upload_max_size" default:"104857600"`
+	DeletePathPrefixes       []string       `envconfig:"delete_path_prefixes" default:""`
+	BaseURL                  string         `envconfig:"base_url" default:""`
+	AuthType                 string         `envconfig:"auth_type" default:"none"`
+	OIDCProvider             string         `envconfig:"oidc_provider" default:"https://accounts.google.com"`
+	OIDCScopes               []string       `envconfig:"oidc_scopes" default:"openid,profile,email"`
+	OIDCAuthorizeURL         string         `envconfig:"oidc_authorize_url" default:""`
+	OIDCTokenURL             string         `envconfig:"oidc_token_url" default:""`
+	OIDCClientID             string         `envconfig:"oidc_client_id" default:""`
+	OIDCClientSecret         string         `envconfig:"oidc_client_secret" default:""`
+	OIDCGoogleHostedDomain   string         `envconfig:"oidc_google_hosted_domain" default:""`
+	JWTExpiration            int64          `envconfig:"jwt_expiration" default:"3600"`
+	JWTSecret                string         `envconfig:"jwt_secret"`
+	BasicAuthUser            string         `envconfig:"basic_auth_user" default:""`
+	BasicAuthPassword        string         `envconfig:"basic_auth_password" default:""`
 }
 
 var conf Config
@@ -58,6 +95,181 @@ func NotFoundPage() string {
 	return conf.NotFoundPage
 }
 
+// SPAEntryDocument returns the entry document served for extensionless paths of single-page apps
+func SPAEntryDocument() string {
+	return conf.SPAEntryDocument
+}
+
+// VirtualHosts returns host to bucket mappings
+func VirtualHosts() VirtualHostMap {
+	return conf.VirtualHosts
+}
+
+// Mounts returns URL path prefix to bucket mappings of the default host
+func Mounts() MountMap {
+	return conf.Mounts
+}
+
+// PrecompressedEncodings returns content codings (br, gzip, zstd) of pre-compressed sibling objects in order of preference
+func PrecompressedEncodings() []string {
+	return conf.PrecompressedEncodings
+}
+
+func PrecompressedCacheTTL() int64 {
+	return conf.PrecompressedCacheTTL
+}
+
+// CompressionEncodings returns content codings (br, zstd, gzip) to compress responses on the fly in order of preference
+func CompressionEncodings() []string {
+	return conf.CompressionEncodings
+}
+
+func CompressionMIMETypes() []string {
+	return conf.CompressionMIMETypes
+}
+
+func CompressionMinSize() int64 {
+	return conf.CompressionMinSize
+}
+
+// ObjectCacheMaxMemory returns the maximum bytes of the in-memory object cache, or 0 if disabled
+func ObjectCacheMaxMemory() int64 {
+	return conf.ObjectCacheMaxMemory
+}
+
+// ObjectCacheTTL returns the seconds to cache object attributes and contents
+func ObjectCacheTTL() int64 {
+	return conf.ObjectCacheTTL
+}
+
+// ObjectCacheMaxObjectSize returns the maximum size of objects whose contents are cached in memory
+func ObjectCacheMaxObjectSize() int64 {
+	return conf.ObjectCacheMaxObjectSize
+}
+
+// DiskCacheDir returns the directory of the on-disk object cache, or an empty string if disabled
+func DiskCacheDir() string {
+	return conf.DiskCacheDir
+}
+
+// DiskCacheMaxSize returns the maximum bytes of the on-disk object cache
+func DiskCacheMaxSize() int64 {
+	return conf.DiskCacheMaxSize
+}
+
+// DiskCacheMinObjectSize returns the minimum size of objects whose contents are cached on disk
+func DiskCacheMinObjectSize() int64 {
+	return conf.DiskCacheMinObjectSize
+}
+
+// MIMETypes returns the MIME types by extension for objects without a content type, taking precedence over the system MIME table
+func MIMETypes() MIMETypeMap {
+	return conf.MIMETypes
+}
+
+// ContentTypeSniffing returns whether to detect the content type from the contents of objects without a content type and a known extension
+func ContentTypeSniffing() bool {
+	return conf.ContentTypeSniffing
+}
+
+// MetadataHeaderPrefix returns the prefix of the custom metadata keys written as response headers
+func MetadataHeaderPrefix() string {
+	return conf.MetadataHeaderPrefix
+}
+
+// MetadataHeaders returns the response headers allowed to be written from the custom metadata
+func MetadataHeaders() []string {
+	return conf.MetadataHeaders
+}
+
+// HeaderRules returns the rules to modify the response headers
+func HeaderRules() HeaderRuleList {
+	return conf.HeaderRules
+}
+
+// CORSAllowedOrigins returns the origins allowed by CORS ("*" or "https://*.example.com" allowed), or empty if CORS is disabled
+func CORSAllowedOrigins() []string {
+	return conf.CORSAllowedOrigins
+}
+
+func CORSAllowedMethods() []string {
+	return conf.CORSAllowedMethods
+}
+
+func CORSAllowedHeaders() []string {
+	return conf.CORSAllowedHeaders
+}
+
+func CORSExposedHeaders() []string {
+	return conf.CORSExposedHeaders
+}
+
+func CORSAllowCredentials() bool {
+	return conf.CORSAllowCredentials
+}
+
+func CORSMaxAge() int {
+	return conf.CORSMaxAge
+}
+
+// CORSPathPrefixes returns the path prefixes where CORS applies, or empty for all paths
+func CORSPathPrefixes() []string {
+	return conf.CORSPathPrefixes
+}
+
+// RedirectsFile returns the name of the redirect rules file (e.g. "_redirects" or "gcsproxy.json") at the root of the site, or an empty string if disabled
+func RedirectsFile() string {
+	return conf.RedirectsFile
+}
+
+// RedirectsReloadInterval returns the seconds between checks of the generation of the redirect rules file
+func RedirectsReloadInterval() int64 {
+	return conf.RedirectsReloadInterval
+}
+
+// RedirectsCountryHeader returns the request header of the client country code for the Country conditions
+func RedirectsCountryHeader() string {
+	return conf.RedirectsCountryHeader
+}
+
+// ErrorPages returns the objects of the error pages by status, relative to the prefix of the site
+func ErrorPages() ErrorPageMap {
+	return conf.ErrorPages
+}
+
+// ErrorPagesReloadInterval returns the seconds between checks of the generation of the error page objects
+func ErrorPagesReloadInterval() int64 {
+	return conf.ErrorPagesReloadInterval
+}
+
+// DirectoryListing returns whether to render directory listings for prefixes without a main page
+func DirectoryListing() bool {
+	return conf.DirectoryListing
+}
+
+func DirectoryListingPageSize() int {
+	return conf.DirectoryListingPageSize
+}
+
+// JSONAPI returns whether to serve the JSON API to list objects
+func JSONAPI() bool {
+	return conf.JSONAPI
+}
+
+// WritePathPrefixes returns path prefixes where uploads are allowed. Uploads are disabled if empty.
+func WritePathPrefixes() []string {
+	return conf.WritePathPrefixes
+}
+
+func UploadMaxSize() int64 {
+	return conf.UploadMaxSize
+}
+
+// DeletePathPrefixes returns path prefixes where deletions are allowed. Deletions are disabled if empty.
+func DeletePathPrefixes() []string {
+	return conf.DeletePathPrefixes
+}
+
 // BaseURL returns base URL
 func BaseURL() string {
 	return conf.BaseURL
@@ -154,3 +366,43 @@ func ValidateBasicAuth() error {
 
 	return nil
 }
+
+func ValidateWrite() error {
+	if len(WritePathPrefixes()) == 0 && len(DeletePathPrefixes()) == 0 {
+		return nil
+	}
+
+	if AuthType() != "oidc" && AuthType() != "basic" {
+		return fmt.Errorf("config.ValidateWrite: AUTH_TYPE must be oidc or basic when WRITE_PATH_PREFIXES or DELETE_PATH_PREFIXES is set")
+	}
+
+	return nil
+}
+
+// ValidateDirectoryListing rejects a page size the listing pager cannot use
+func ValidateDirectoryListing() error {
+	if !DirectoryListing() {
+		return nil
+	}
+
+	if DirectoryListingPageSize() <= 0 {
+		return fmt.Errorf("config.ValidateDirectoryListing: DIRECTORY_LISTING_PAGE_SIZE must be greater than 0")
+	}
+
+	return nil
+}
+
+// ValidateCORS rejects allowing all origins with credentials, which would let any website read private objects with the session of the user
+func ValidateCORS() error {
+	if !CORSAllowCredentials() {
+		return nil
+	}
+
+	for _, o := range CORSAllowedOrigins() {
+		if o == "*" {
+			return fmt.Errorf("config.ValidateCORS: CORS_ALLOWED_ORIGINS must not contain * when CORS_ALLOW_CREDENTIALS is true")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// ErrorPageMap maps status codes (e.g. "404"), status classes (e.g. "5xx") or "default" to the object of the error page.
+type ErrorPageMap map[string]string
+
+// Decode decodes ErrorPageMap from JSON.
+func (m *ErrorPageMap) Decode(value string) error {
+	pages := make(map[string]string)
+	if err := json.Unmarshal([]byte(value), &pages); err != nil {
+		return fmt.Errorf("config.ErrorPageMap.Decode: failed to decode: %w", err)
+	}
+
+	for k := range pages {
+		if !isErrorPageKey(k) {
+			return fmt.Errorf("config.ErrorPageMap.Decode: invalid status: %s", k)
+		}
+	}
+
+	*m = pages
+	return nil
+}
+
+// Page returns the object of the error page for the status, preferring the status code to the status class and "default".
+func (m ErrorPageMap) Page(status int) (string, bool) {
+	for _, k := range []string{strconv.Itoa(status), strconv.Itoa(status/100) + "xx", "default"} {
+		if p, ok := m[k]; ok && len(p) > 0 {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// isErrorPageKey reports whether the key is an error status code, an error status class or "default".
+func isErrorPageKey(k string) bool {
+	switch {
+	case k == "default", k == "4xx", k == "5xx":
+		return true
+	case len(k) == 3:
+		code, err := strconv.Atoi(k)
+		return err == nil && code >= 400 && code <= 599
+	default:
+		return false
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/aplulu/gcsproxy/internal/util"
+)
+
+// HeaderRule modifies the response headers of the requests matching the path and the content type.
+type HeaderRule struct {
+	// Path is the glob pattern of the request path (e.g. "/assets/**").
+	Path string `json:"path"`
+	// PathRegex is the regular expression of the request path.
+	PathRegex string `json:"pathRegex"`
+	// ContentType is the media type of the response (e.g. "text/html" or "image/*").
+	ContentType string            `json:"contentType"`
+	Set         map[string]string `json:"set"`
+	Append      map[string]string `json:"append"`
+	Remove      []string          `json:"remove"`
+}
+
+// PathRegexp returns the regular expression matching the request path, or nil if the rule matches all paths.
+func (r HeaderRule) PathRegexp() (*regexp.Regexp, error) {
+	if len(r.PathRegex) > 0 {
+		return regexp.Compile(r.PathRegex)
+	}
+	if len(r.Path) > 0 {
+		return util.GlobToRegexp(r.Path)
+	}
+	return nil, nil
+}
+
+// HeaderRuleList is the list of HeaderRule applied in order.
+type HeaderRuleList []HeaderRule
+
+// Decode decodes HeaderRuleList from JSON.
+func (l *HeaderRuleList) Decode(value string) error {
+	var rules []HeaderRule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return fmt.Errorf("config.HeaderRuleList.Decode: failed to decode: %w", err)
+	}
+
+	for i, rule := range rules {
+		if len(rule.Path) > 0 && len(rule.PathRegex) > 0 {
+			return fmt.Errorf("config.HeaderRuleList.Decode: both path and pathRegex are set: rule %d", i)
+		}
+		if _, err := rule.PathRegexp(); err != nil {
+			return fmt.Errorf("config.HeaderRuleList.Decode: invalid path: rule %d: %w", i, err)
+		}
+	}
+
+	*l = rules
+	return nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// MIMETypeMap maps file extensions (e.g. ".md") to MIME types.
+type MIMETypeMap map[string]string
+
+// Decode decodes MIMETypeMap from JSON, normalizing the extensions to lower case with a leading ".".
+func (m *MIMETypeMap) Decode(value string) error {
+	types := make(map[string]string)
+	if err := json.Unmarshal([]byte(value), &types); err != nil {
+		return fmt.Errorf("config.MIMETypeMap.Decode: failed to decode: %w", err)
+	}
+
+	*m = make(MIMETypeMap, len(types))
+	for ext, typ := range types {
+		if _, _, err := mime.ParseMediaType(typ); err != nil {
+			return fmt.Errorf("config.MIMETypeMap.Decode: invalid MIME type of %s: %w", ext, err)
+		}
+		(*m)["."+strings.ToLower(strings.TrimPrefix(ext, "."))] = typ
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Mount is the bucket and settings to serve for a URL path prefix.
+type Mount struct {
+	Bucket           string  `json:"bucket"`
+	Prefix           string  `json:"prefix"`
+	MainPageSuffix   *string `json:"mainPageSuffix"`
+	NotFoundPage     *string `json:"notFoundPage"`
+	SPAEntryDocument *string `json:"spaEntryDocument"`
+}
+
+// MountMap maps URL path prefixes (e.g. "/assets") to Mount.
+type MountMap map[string]Mount
+
+// Decode decodes MountMap from JSON.
+func (m *MountMap) Decode(value string) error {
+	if err := json.Unmarshal([]byte(value), m); err != nil {
+		return fmt.Errorf("config.MountMap.Decode: failed to decode: %w", err)
+	}
+
+	return nil
+}
+
+// UnmarshalJSON decodes MountMap, normalizing the path prefixes to begin with "/" and not to end with "/".
+func (m *MountMap) UnmarshalJSON(data []byte) error {
+	mounts := make(map[string]Mount)
+	if err := json.Unmarshal(data, &mounts); err != nil {
+		return err
+	}
+
+	*m = make(MountMap, len(mounts))
+	for p, mount := range mounts {
+		mount.Prefix = normalizeObjectPrefix(mount.Prefix)
+		(*m)["/"+strings.Trim(p, "/")] = mount
+	}
+
+	return nil
+}
+
+// VirtualHost is the bucket and settings to serve for a host.
+type VirtualHost struct {
+	Mount
+	Mounts MountMap `json:"mounts"`
+}
+
+// VirtualHostMap maps host names (e.g. "docs.example.com" or "*.example.com") to VirtualHost.
+type VirtualHostMap map[string]VirtualHost
+
+// Decode decodes VirtualHostMap from JSON.
+func (v *VirtualHostMap) Decode(value string) error {
+	hosts := make(map[string]VirtualHost)
+	if err := json.Unmarshal([]byte(value), &hosts); err != nil {
+		return fmt.Errorf("config.VirtualHostMap.Decode: failed to decode: %w", err)
+	}
+
+	*v = make(VirtualHostMap, len(hosts))
+	for host, vh := range hosts {
+		if vh.Bucket == "" {
+			return fmt.Errorf("config.VirtualHostMap.Decode: bucket is required: %s", host)
+		}
+		vh.Prefix = normalizeObjectPrefix(vh.Prefix)
+		(*v)[strings.ToLower(host)] = vh
+	}
+
+	return nil
+}
+
+// normalizeObjectPrefix appends "/" to the non-empty object prefix, so that "v2" maps "app.js" to "v2/app.js" rather than "v2app.js".
+func normalizeObjectPrefix(prefix string) string {
+	if len(prefix) > 0 && !strings.HasSuffix(prefix, "/") {
+		return prefix + "/"
+	}
+	return prefix
+}
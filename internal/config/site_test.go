@@ -0,0 +1,19 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtualHostMap_Decode(t *testing.T) {
+	var v VirtualHostMap
+	err := v.Decode(`{"Docs.Example.com": {"bucket": "docs", "prefix": "v2", "mounts": {"/assets/": {"prefix": "static"}, "/img": {"prefix": "images/"}, "/raw": {}}}}`)
+	assert.NoError(t, err)
+
+	vh := v["docs.example.com"]
+	assert.Equal(t, "v2/", vh.Prefix)
+	assert.Equal(t, "static/", vh.Mounts["/assets"].Prefix)
+	assert.Equal(t, "images/", vh.Mounts["/img"].Prefix)
+	assert.Equal(t, "", vh.Mounts["/raw"].Prefix)
+}
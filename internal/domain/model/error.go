@@ -8,4 +8,12 @@ var (
 	ErrInvalidState         = errors.New("invalid state")
 	ErrInvalidHostedDomain  = errors.New("invalid hosted domain")
 	ErrStreamingUnsupported = errors.New("streaming is unsupported")
+	ErrInvalidRange         = errors.New("invalid range")
+	ErrRangeNotSatisfiable  = errors.New("range not satisfiable")
+	ErrNotModified          = errors.New("not modified")
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrForbidden            = errors.New("forbidden")
+	ErrRequestTooLarge      = errors.New("request entity too large")
+	ErrInvalidObjectName    = errors.New("invalid object name")
+	ErrInvalidRequest       = errors.New("invalid request")
 )
@@ -0,0 +1,159 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	// MaxObjectListPageSize is the maximum number of entries returned by ListObjects.
+	MaxObjectListPageSize = 1000
+)
+
+type Object struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"contentType"`
+	Generation  int64     `json:"generation"`
+	Updated     time.Time `json:"updated"`
+}
+
+func newObject(attrs *storage.ObjectAttrs) *Object {
+	return &Object{
+		Name:        attrs.Name,
+		Size:        attrs.Size,
+		ContentType: attrs.ContentType,
+		Generation:  attrs.Generation,
+		Updated:     attrs.Updated,
+	}
+}
+
+type ObjectList struct {
+	Objects       []*Object `json:"objects"`
+	Prefixes      []string  `json:"prefixes"`
+	NextPageToken string    `json:"nextPageToken,omitempty"`
+}
+
+// ListObjects lists objects and sub-prefixes under the prefix.
+// The prefix and the returned names are relative to root.
+func ListObjects(ctx context.Context, storageBucket *storage.BucketHandle, root string, prefix string, delimiter string, pageToken string, pageSize int) (*ObjectList, error) {
+	if pageSize <= 0 || pageSize > MaxObjectListPageSize {
+		pageSize = MaxObjectListPageSize
+	}
+
+	q := &storage.Query{
+		Prefix:    root + prefix,
+		Delimiter: delimiter,
+	}
+	if err := q.SetAttrSelection([]string{"Name", "Size", "ContentType", "Generation", "Updated"}); err != nil {
+		return nil, fmt.Errorf("model.ListObjects: failed to set attr selection: %w", err)
+	}
+
+	var attrs []*storage.ObjectAttrs
+	nextPageToken, err := iterator.NewPager(storageBucket.Objects(ctx, q), pageSize, pageToken).NextPage(&attrs)
+	if err != nil {
+		return nil, fmt.Errorf("model.ListObjects: failed to list objects: %w", err)
+	}
+
+	list := &ObjectList{
+		Objects:       make([]*Object, 0, len(attrs)),
+		Prefixes:      make([]string, 0),
+		NextPageToken: nextPageToken,
+	}
+	for _, a := range attrs {
+		if len(a.Prefix) > 0 {
+			list.Prefixes = append(list.Prefixes, strings.TrimPrefix(a.Prefix, root))
+			continue
+		}
+
+		obj := newObject(a)
+		obj.Name = strings.TrimPrefix(obj.Name, root)
+		list.Objects = append(list.Objects, obj)
+	}
+
+	return list, nil
+}
+
+// WriteObject writes the content to the object.
+// If generationMatch is not nil, the object is written only if its generation matches (0 means the object must not exist).
+func WriteObject(ctx context.Context, storageBucket *storage.BucketHandle, key string, r io.Reader, contentType string, generationMatch *int64) (*Object, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("model.WriteObject: empty object name: %w", ErrInvalidObjectName)
+	}
+
+	// cancel the context to abort the upload without committing the object
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	obj := storageBucket.Object(key)
+	if generationMatch != nil {
+		if *generationMatch == 0 {
+			obj = obj.If(storage.Conditions{DoesNotExist: true})
+		} else {
+			obj = obj.If(storage.Conditions{GenerationMatch: *generationMatch})
+		}
+	}
+
+	w := obj.NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := io.Copy(w, r); err != nil {
+		cancel()
+		_ = w.Close()
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			return nil, fmt.Errorf("model.WriteObject: request body too large: %w", ErrRequestTooLarge)
+		}
+		return nil, fmt.Errorf("model.WriteObject: failed to write object: %w", convertStorageError(err))
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("model.WriteObject: failed to close writer: %w", convertStorageError(err))
+	}
+
+	return newObject(w.Attrs()), nil
+}
+
+// DeleteObject deletes the object.
+// If generationMatch is not nil, the object is deleted only if its generation matches (0, requiring the object not to exist, never matches).
+func DeleteObject(ctx context.Context, storageBucket *storage.BucketHandle, key string, generationMatch *int64) error {
+	if len(key) == 0 {
+		return fmt.Errorf("model.DeleteObject: empty object name: %w", ErrInvalidObjectName)
+	}
+
+	obj := storageBucket.Object(key)
+	if generationMatch != nil {
+		// generation 0 requires the object not to exist, which never holds for the object to delete
+		if *generationMatch == 0 {
+			return fmt.Errorf("model.DeleteObject: object must not exist: %w", ErrPreconditionFailed)
+		}
+		obj = obj.If(storage.Conditions{GenerationMatch: *generationMatch})
+	}
+
+	if err := obj.Delete(ctx); err != nil {
+		return fmt.Errorf("model.DeleteObject: failed to delete object: %w", convertStorageError(err))
+	}
+
+	return nil
+}
+
+// convertStorageError converts the Cloud Storage API error to the domain error.
+func convertStorageError(err error) error {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		switch gerr.Code {
+		case http.StatusPreconditionFailed:
+			return fmt.Errorf("%v: %w", err, ErrPreconditionFailed)
+		case http.StatusNotFound:
+			return fmt.Errorf("%v: %w", err, storage.ErrObjectNotExist)
+		}
+	}
+	return err
+}
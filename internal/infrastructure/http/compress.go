@@ -0,0 +1,146 @@
+package http
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+	"github.com/aplulu/gcsproxy/internal/util"
+)
+
+// compressWriter is the writer of the content coding.
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressWriterPools pools the writers per content coding since creating them is expensive.
+var compressWriterPools = map[string]*sync.Pool{
+	"br": {New: func() interface{} {
+		return brotli.NewWriterLevel(nil, 4)
+	}},
+	"gzip": {New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}},
+	"zstd": {New: func() interface{} {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+}
+
+// compressResponseWriter compresses the response body with the content coding.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	cw       compressWriter
+}
+
+func newCompressResponseWriter(w http.ResponseWriter, encoding string) *compressResponseWriter {
+	cw := compressWriterPools[encoding].Get().(compressWriter)
+	cw.Reset(w)
+	return &compressResponseWriter{
+		ResponseWriter: w,
+		encoding:       encoding,
+		cw:             cw,
+	}
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	return w.cw.Write(b)
+}
+
+func (w *compressResponseWriter) Flush() {
+	if err := w.cw.Flush(); err != nil {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close flushes the remaining compressed data and returns the writer to the pool.
+func (w *compressResponseWriter) Close() error {
+	err := w.cw.Close()
+	w.cw.Reset(io.Discard)
+	compressWriterPools[w.encoding].Put(w.cw)
+	return err
+}
+
+// isCompressible reports whether the object can be compressed on the fly.
+func isCompressible(attrs *storage.ObjectAttrs) bool {
+	if len(config.CompressionEncodings()) == 0 || len(attrs.ContentEncoding) > 0 {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(attrs.ContentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range config.CompressionMIMETypes() {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateCompression returns the content coding to compress the object on the fly, or an empty string.
+// Range requests are served uncompressed so that the byte ranges refer to the stored content.
+func negotiateCompression(req *http.Request, attrs *storage.ObjectAttrs) string {
+	if attrs.Size < config.CompressionMinSize() || len(req.Header.Get("Range")) > 0 {
+		return ""
+	}
+
+	for _, encoding := range config.CompressionEncodings() {
+		if _, ok := compressWriterPools[encoding]; ok && util.AcceptsEncoding(req, encoding) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// representationETag returns the entity-tag of the representation with the content coding.
+func representationETag(etag string, encoding string) string {
+	return strings.TrimSuffix(etag, "\"") + "-" + encoding + "\""
+}
+
+// writeCompressionHeaders overrides the headers of the object for the compressed representation.
+func writeCompressionHeaders(w http.ResponseWriter, encoding string, etag string) {
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Encoding", encoding)
+	w.Header().Set("ETag", etag)
+}
+
+// isGzipEncoded reports whether the object is stored with gzip Content-Encoding, which is subject to the decompressive transcoding of GCS.
+func isGzipEncoded(attrs *storage.ObjectAttrs) bool {
+	return strings.EqualFold(attrs.ContentEncoding, "gzip")
+}
+
+// writeDecompressionHeaders overrides the headers of the gzip-encoded object for the decompressed representation.
+// The Content-Length of the stored bytes does not apply to the decompressed content.
+func writeDecompressionHeaders(w http.ResponseWriter, etag string) {
+	w.Header().Del("Content-Length")
+	w.Header().Del("Content-Encoding")
+	w.Header().Set("ETag", etag)
+}
+
+// addVary adds the header name to the Vary header unless already present.
+func addVary(w http.ResponseWriter, name string) {
+	for _, v := range w.Header().Values("Vary") {
+		for _, n := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(n), name) {
+				return
+			}
+		}
+	}
+	w.Header().Add("Vary", name)
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressResponseWriter(t *testing.T) {
+	content := strings.Repeat("gcsproxy compresses responses on the fly. ", 100)
+
+	testCases := []struct {
+		encoding  string
+		newReader func(r io.Reader) (io.Reader, error)
+	}{{
+		encoding: "br",
+		newReader: func(r io.Reader) (io.Reader, error) {
+			return brotli.NewReader(r), nil
+		},
+	}, {
+		encoding: "gzip",
+		newReader: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	}, {
+		encoding: "zstd",
+		newReader: func(r io.Reader) (io.Reader, error) {
+			return zstd.NewReader(r)
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.encoding, func(t *testing.T) {
+			// run twice to reuse the pooled writer
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+
+				cw := newCompressResponseWriter(w, tc.encoding)
+				_, err := io.Copy(cw, strings.NewReader(content))
+				assert.NoError(t, err)
+				assert.NoError(t, cw.Close())
+				assert.Less(t, w.Body.Len(), len(content))
+
+				r, err := tc.newReader(bytes.NewReader(w.Body.Bytes()))
+				assert.NoError(t, err)
+				got, err := io.ReadAll(r)
+				assert.NoError(t, err)
+				assert.Equal(t, content, string(got))
+			}
+		})
+	}
+}
+
+func TestRepresentationETag(t *testing.T) {
+	assert.Equal(t, `"abc-123-br"`, representationETag(`"abc-123"`, "br"))
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/aplulu/gcsproxy/internal/domain/model"
+)
+
+// objectETag returns a strong entity-tag derived from the object hash and generation.
+func objectETag(attrs *storage.ObjectAttrs) string {
+	var hash string
+	if len(attrs.MD5) > 0 {
+		hash = hex.EncodeToString(attrs.MD5)
+	} else {
+		// composite objects have no MD5 hash
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, attrs.CRC32C)
+		hash = hex.EncodeToString(b)
+	}
+
+	return "\"" + hash + "-" + strconv.FormatInt(attrs.Generation, 10) + "\""
+}
+
+// checkPreconditions evaluates the conditional request headers against the entity-tag of the representation as per RFC 7232.
+// It returns model.ErrPreconditionFailed or model.ErrNotModified if the request should not be served.
+func checkPreconditions(req *http.Request, attrs *storage.ObjectAttrs, etag string) error {
+	modTime := attrs.Updated.Truncate(time.Second)
+
+	if im := req.Header.Get("If-Match"); len(im) > 0 {
+		if !matchETag(im, etag, false) {
+			return model.ErrPreconditionFailed
+		}
+	} else if ius := req.Header.Get("If-Unmodified-Since"); len(ius) > 0 {
+		if t, err := http.ParseTime(ius); err == nil && modTime.After(t) {
+			return model.ErrPreconditionFailed
+		}
+	}
+
+	if inm := req.Header.Get("If-None-Match"); len(inm) > 0 {
+		if matchETag(inm, etag, true) {
+			if req.Method == http.MethodGet || req.Method == http.MethodHead {
+				return model.ErrNotModified
+			}
+			return model.ErrPreconditionFailed
+		}
+	} else if ims := req.Header.Get("If-Modified-Since"); len(ims) > 0 && (req.Method == http.MethodGet || req.Method == http.MethodHead) {
+		if t, err := http.ParseTime(ims); err == nil && !modTime.After(t) {
+			return model.ErrNotModified
+		}
+	}
+
+	return nil
+}
+
+// matchETag reports whether the entity-tag list in the header matches the etag.
+// If weak is true, the weak comparison function is used.
+func matchETag(header string, etag string, weak bool) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = textproto.TrimString(v)
+		if v == "*" {
+			return true
+		}
+		if strings.HasPrefix(v, "W/") {
+			if !weak {
+				continue
+			}
+			v = v[2:]
+		}
+		if v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified writes the 304 Not Modified response.
+func writeNotModified(w http.ResponseWriter, attrs *storage.ObjectAttrs, etag string) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+
+	writeStringHeader(w, "ETag", etag)
+	writeStringHeader(w, "Last-Modified", attrs.Updated.Format(http.TimeFormat))
+	writeCacheControlHeader(w, attrs)
+	w.WriteHeader(http.StatusNotModified)
+}
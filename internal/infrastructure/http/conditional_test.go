@@ -0,0 +1,135 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+	"github.com/aplulu/gcsproxy/internal/domain/model"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	updated := time.Date(2023, 1, 2, 3, 4, 5, 600, time.UTC)
+	attrs := &storage.ObjectAttrs{
+		MD5:        []byte{0xde, 0xad, 0xbe, 0xef},
+		Generation: 1234,
+		Updated:    updated,
+	}
+	etag := objectETag(attrs)
+
+	testCases := []struct {
+		name    string
+		method  string
+		header  http.Header
+		wantErr error
+	}{{
+		name:   "No conditions",
+		method: http.MethodGet,
+		header: http.Header{},
+	}, {
+		name:    "If-None-Match matches",
+		method:  http.MethodGet,
+		header:  http.Header{"If-None-Match": {`"other", ` + etag}},
+		wantErr: model.ErrNotModified,
+	}, {
+		name:    "If-None-Match matches weakly",
+		method:  http.MethodGet,
+		header:  http.Header{"If-None-Match": {"W/" + etag}},
+		wantErr: model.ErrNotModified,
+	}, {
+		name:   "If-None-Match does not match",
+		method: http.MethodGet,
+		header: http.Header{"If-None-Match": {`"other"`}},
+	}, {
+		name:    "If-Modified-Since not modified",
+		method:  http.MethodGet,
+		header:  http.Header{"If-Modified-Since": {updated.Format(http.TimeFormat)}},
+		wantErr: model.ErrNotModified,
+	}, {
+		name:   "If-Modified-Since modified",
+		method: http.MethodGet,
+		header: http.Header{"If-Modified-Since": {updated.Add(-time.Hour).Format(http.TimeFormat)}},
+	}, {
+		name:   "If-None-Match takes precedence over If-Modified-Since",
+		method: http.MethodGet,
+		header: http.Header{
+			"If-None-Match":     {`"other"`},
+			"If-Modified-Since": {updated.Format(http.TimeFormat)},
+		},
+	}, {
+		name:    "If-Match does not match",
+		method:  http.MethodGet,
+		header:  http.Header{"If-Match": {`"other"`}},
+		wantErr: model.ErrPreconditionFailed,
+	}, {
+		name:   "If-Match matches",
+		method: http.MethodGet,
+		header: http.Header{"If-Match": {etag}},
+	}, {
+		name:    "If-Unmodified-Since modified",
+		method:  http.MethodGet,
+		header:  http.Header{"If-Unmodified-Since": {updated.Add(-time.Hour).Format(http.TimeFormat)}},
+		wantErr: model.ErrPreconditionFailed,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &http.Request{Method: tc.method, Header: tc.header}
+
+			err := checkPreconditions(req, attrs, etag)
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestServeObject_Conditional(t *testing.T) {
+	assert.NoError(t, config.LoadConf())
+
+	testCases := []struct {
+		name        string
+		ifNoneMatch func(etag string) string
+		wantStatus  int
+		wantBody    string
+		wantReads   int
+	}{{
+		name:        "If-None-Match match",
+		ifNoneMatch: func(etag string) string { return etag },
+		wantStatus:  http.StatusNotModified,
+	}, {
+		name:        "If-None-Match mismatch",
+		ifNoneMatch: func(etag string) string { return `"other"` },
+		wantStatus:  http.StatusOK,
+		wantBody:    "contents",
+		wantReads:   1,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcs, bucket := newFakeGCS(t)
+			gcs.put("a.txt", "contents", "text/plain")
+			attrs, err := bucket.Object("a.txt").Attrs(context.Background())
+			assert.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+			req.Header.Set("If-None-Match", tc.ifNoneMatch(objectETag(attrs)))
+			w := httptest.NewRecorder()
+			serveObject(w, req, &site{bucket: bucket}, "a.txt", http.StatusOK)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			assert.Equal(t, tc.wantBody, w.Body.String())
+			assert.Equal(t, objectETag(attrs), w.Header().Get("ETag"))
+			assert.Equal(t, tc.wantReads, gcs.readsCount())
+		})
+	}
+}
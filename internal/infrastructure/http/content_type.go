@@ -0,0 +1,99 @@
+package http
+
+import (
+	"context"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+	"github.com/aplulu/gcsproxy/pkg/lrucache"
+)
+
+const (
+	// sniffLen is the number of bytes to detect the content type, as http.DetectContentType considers.
+	sniffLen                 = 512
+	sniffCacheMaxEntries     = 10000
+	sniffCacheTTL            = 24 * time.Hour
+	defaultObjectContentType = "application/octet-stream"
+)
+
+// sniffedContentTypes caches the detected content types by object generation.
+var sniffedContentTypes = lrucache.New(sniffCacheMaxEntries, sniffCacheTTL)
+
+// inferContentType returns the attributes with the content type inferred from the extension of the key or the contents,
+// if the content type of the object is missing or application/octet-stream. The cached attributes are not modified.
+func inferContentType(ctx context.Context, obj *storage.ObjectHandle, key string, attrs *storage.ObjectAttrs) *storage.ObjectAttrs {
+	if !hasGenericContentType(attrs) {
+		return attrs
+	}
+
+	contentType := typeByExtension(key)
+	// compressed contents cannot be sniffed
+	if len(contentType) == 0 && config.ContentTypeSniffing() && len(attrs.ContentEncoding) == 0 && attrs.Size > 0 {
+		contentType = sniffContentType(ctx, obj, attrs)
+	}
+	if len(contentType) == 0 || contentType == defaultObjectContentType {
+		return attrs
+	}
+
+	a := *attrs
+	a.ContentType = contentType
+	return &a
+}
+
+// hasGenericContentType reports whether the content type of the object is missing or application/octet-stream.
+func hasGenericContentType(attrs *storage.ObjectAttrs) bool {
+	if len(attrs.ContentType) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(attrs.ContentType)
+	return err != nil || mediaType == defaultObjectContentType
+}
+
+// typeByExtension returns the MIME type of the extension of the key from MIME_TYPES or the system MIME table.
+func typeByExtension(key string) string {
+	ext := strings.ToLower(path.Ext(key))
+	if len(ext) == 0 {
+		return ""
+	}
+	if t, ok := config.MIMETypes()[ext]; ok {
+		return t
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// sniffContentType detects the content type from the first bytes of the object generation in the attributes.
+func sniffContentType(ctx context.Context, obj *storage.ObjectHandle, attrs *storage.ObjectAttrs) string {
+	cacheKey := bodyCacheKey(attrs)
+	if v, ok := sniffedContentTypes.Get(cacheKey); ok {
+		return v.(string)
+	}
+
+	length := int64(sniffLen)
+	if attrs.Size < length {
+		length = attrs.Size
+	}
+	r, err := cachedObjects.newRangeReader(ctx, obj.Generation(attrs.Generation), attrs, 0, length)
+	if err != nil {
+		log.Printf("http.sniffContentType: failed to open range reader: %v\n", err)
+		return ""
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		log.Printf("http.sniffContentType: failed to read object: %v\n", err)
+		return ""
+	}
+
+	contentType := http.DetectContentType(b)
+	sniffedContentTypes.Set(cacheKey, contentType, 1)
+	return contentType
+}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"mime"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+)
+
+func TestInferContentType(t *testing.T) {
+	t.Setenv("MIME_TYPES", `{"md": "text/markdown; charset=utf-8", ".WASM": "application/wasm"}`)
+	t.Setenv("CONTENT_TYPE_SNIFFING", "true")
+	assert.NoError(t, config.LoadConf())
+
+	// the sniffed content type is cached by generation
+	sniffedContentTypes.Set(bodyCacheKey(&storage.ObjectAttrs{Bucket: "bucket", Name: "LICENSE", Generation: 1}), "text/plain; charset=utf-8", 1)
+
+	testCases := []struct {
+		name  string
+		key   string
+		attrs *storage.ObjectAttrs
+		want  string
+	}{{
+		name:  "Content type set",
+		key:   "index.html",
+		attrs: &storage.ObjectAttrs{ContentType: "text/plain"},
+		want:  "text/plain",
+	}, {
+		name:  "Missing content type",
+		key:   "docs/index.html",
+		attrs: &storage.ObjectAttrs{},
+		want:  mime.TypeByExtension(".html"),
+	}, {
+		name:  "Generic content type",
+		key:   "app.js",
+		attrs: &storage.ObjectAttrs{ContentType: "application/octet-stream"},
+		want:  mime.TypeByExtension(".js"),
+	}, {
+		name:  "Configured extension",
+		key:   "README.MD",
+		attrs: &storage.ObjectAttrs{ContentType: "application/octet-stream"},
+		want:  "text/markdown; charset=utf-8",
+	}, {
+		name:  "Normalized extension",
+		key:   "main.wasm",
+		attrs: &storage.ObjectAttrs{},
+		want:  "application/wasm",
+	}, {
+		name:  "Sniffed",
+		key:   "LICENSE",
+		attrs: &storage.ObjectAttrs{Bucket: "bucket", Name: "LICENSE", Generation: 1, Size: 1024},
+		want:  "text/plain; charset=utf-8",
+	}, {
+		name:  "Compressed contents are not sniffed",
+		key:   "LICENSE",
+		attrs: &storage.ObjectAttrs{Bucket: "bucket", Name: "LICENSE", Generation: 1, Size: 1024, ContentEncoding: "gzip"},
+		want:  "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := inferContentType(context.Background(), nil, tc.key, tc.attrs)
+			assert.Equal(t, tc.want, got.ContentType)
+		})
+	}
+}
+
+func TestInferContentTypeDoesNotModifyAttrs(t *testing.T) {
+	assert.NoError(t, config.LoadConf())
+
+	attrs := &storage.ObjectAttrs{ContentType: "application/octet-stream"}
+	got := inferContentType(context.Background(), nil, "style.css", attrs)
+
+	assert.Equal(t, mime.TypeByExtension(".css"), got.ContentType)
+	assert.Equal(t, "application/octet-stream", attrs.ContentType)
+}
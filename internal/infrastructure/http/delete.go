@@ -0,0 +1,70 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+	"github.com/aplulu/gcsproxy/internal/domain/model"
+)
+
+// serveDelete deletes the object.
+// The If-Match header is mapped to the generation precondition of the object.
+func serveDelete(w http.ResponseWriter, req *http.Request, storageBucket *storage.BucketHandle, key string) {
+	ctx := req.Context()
+
+	if !isDeletable(req.URL.Path) {
+		responseError(w, req, model.ErrForbidden)
+		return
+	}
+	if len(key) == 0 || !isValidObjectName(key) || strings.HasSuffix(key, "/") {
+		responseError(w, req, model.ErrInvalidObjectName)
+		return
+	}
+
+	generationMatch, err := parseGenerationMatch(req)
+	if err != nil {
+		responseError(w, req, err)
+		return
+	}
+
+	if im := req.Header.Get("If-Match"); len(im) > 0 {
+		attrs, err := storageBucket.Object(key).Attrs(ctx)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			// If-Match is false without a current representation
+			responseError(w, req, model.ErrPreconditionFailed)
+			return
+		}
+		if err != nil {
+			responseError(w, req, err)
+			return
+		}
+		if !matchETag(im, objectETag(attrs), false) {
+			responseError(w, req, model.ErrPreconditionFailed)
+			return
+		}
+		if generationMatch != nil && *generationMatch != attrs.Generation {
+			responseError(w, req, model.ErrPreconditionFailed)
+			return
+		}
+
+		// pin the generation so that the object is not replaced in the meantime
+		generationMatch = &attrs.Generation
+	}
+
+	if err := model.DeleteObject(ctx, storageBucket, key, generationMatch); err != nil {
+		responseError(w, req, err)
+		return
+	}
+	cachedObjects.invalidate(storageBucket.Object(key))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isDeletable reports whether deletions are allowed for the path.
+func isDeletable(p string) bool {
+	return hasAnyPrefix(p, config.DeletePathPrefixes())
+}
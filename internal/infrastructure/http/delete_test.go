@@ -0,0 +1,100 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+)
+
+func TestServeDelete(t *testing.T) {
+	t.Setenv("DELETE_PATH_PREFIXES", "/files")
+	assert.NoError(t, config.LoadConf())
+
+	testCases := []struct {
+		name        string
+		path        string
+		header      http.Header
+		ifMatch     func(etag string) string
+		wantStatus  int
+		wantDeleted bool
+	}{{
+		name:        "Delete",
+		path:        "/files/a.txt",
+		wantStatus:  http.StatusNoContent,
+		wantDeleted: true,
+	}, {
+		name:       "Path not deletable",
+		path:       "/other/a.txt",
+		wantStatus: http.StatusForbidden,
+	}, {
+		name:       "Path sharing the prefix",
+		path:       "/files-private/a.txt",
+		wantStatus: http.StatusForbidden,
+	}, {
+		name:       "Missing object",
+		path:       "/files/missing.txt",
+		wantStatus: http.StatusNotFound,
+	}, {
+		name:        "Generation match",
+		path:        "/files/a.txt?ifGenerationMatch=1",
+		wantStatus:  http.StatusNoContent,
+		wantDeleted: true,
+	}, {
+		name:       "Generation mismatch",
+		path:       "/files/a.txt?ifGenerationMatch=2",
+		wantStatus: http.StatusPreconditionFailed,
+	}, {
+		name:       "Generation 0",
+		path:       "/files/a.txt?ifGenerationMatch=0",
+		wantStatus: http.StatusPreconditionFailed,
+	}, {
+		name:       "If-None-Match",
+		path:       "/files/a.txt",
+		header:     http.Header{"If-None-Match": {"*"}},
+		wantStatus: http.StatusPreconditionFailed,
+	}, {
+		name:        "If-Match",
+		path:        "/files/a.txt",
+		ifMatch:     func(etag string) string { return etag },
+		wantStatus:  http.StatusNoContent,
+		wantDeleted: true,
+	}, {
+		name:       "If-Match mismatch",
+		path:       "/files/a.txt",
+		ifMatch:    func(etag string) string { return `"other"` },
+		wantStatus: http.StatusPreconditionFailed,
+	}, {
+		name:       "Missing object with If-Match",
+		path:       "/files/missing.txt",
+		header:     http.Header{"If-Match": {"*"}},
+		wantStatus: http.StatusPreconditionFailed,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcs, bucket := newFakeGCS(t)
+			gcs.put("files/a.txt", "contents", "text/plain")
+
+			req := httptest.NewRequest(http.MethodDelete, tc.path, nil)
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			if tc.ifMatch != nil {
+				attrs, err := bucket.Object("files/a.txt").Attrs(context.Background())
+				assert.NoError(t, err)
+				req.Header.Set("If-Match", tc.ifMatch(objectETag(attrs)))
+			}
+
+			w := httptest.NewRecorder()
+			serveDelete(w, req, bucket, (&site{}).objectKey(req.URL.Path))
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			assert.Equal(t, tc.wantDeleted, gcs.get("files/a.txt") == nil)
+		})
+	}
+}
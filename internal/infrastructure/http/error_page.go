@@ -0,0 +1,57 @@
+package http
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+	"github.com/aplulu/gcsproxy/internal/interface/http/errorpage"
+)
+
+// maxErrorPageSize is the maximum size of the error page objects.
+const maxErrorPageSize = 1 << 20
+
+// errorPages caches the parsed templates of the error page objects and reloads them when their generation changes.
+var errorPages = newGenerationCache("errorpage", func() time.Duration {
+	return time.Duration(config.ErrorPagesReloadInterval()) * time.Second
+}, maxErrorPageSize, func(attrs *storage.ObjectAttrs, r io.Reader) (interface{}, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(attrs.Name).Parse(string(b))
+})
+
+// errorPageLoader returns the loader of the error pages configured by ERROR_PAGES from the bucket of the site.
+// The default page is rendered if the object cannot be loaded.
+func errorPageLoader(sites *siteResolver) errorpage.Loader {
+	return func(r *http.Request, status int) (*template.Template, bool) {
+		page, ok := config.ErrorPages().Page(status)
+		if !ok {
+			return nil, false
+		}
+		s, _, ok := sites.resolve(r)
+		if !ok {
+			return nil, false
+		}
+
+		tmpl, ok := errorPages.get(r.Context(), s.bucket.Object(s.prefix+page)).(*template.Template)
+		return tmpl, ok
+	}
+}
+
+// loginURL returns the URL of the OIDC login page that redirects back to the request path.
+// Requests to the proxy paths such as the OIDC callback redirect back to the root.
+func loginURL(r *http.Request) string {
+	redirect := r.URL.Path
+	if strings.HasPrefix(redirect, gcsProxyPathPrefix) {
+		redirect = "/"
+	}
+	return config.BaseURL() + oidcPathPrefix + "/login?" + url.Values{"redirect": {redirect}}.Encode()
+}
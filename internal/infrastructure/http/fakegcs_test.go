@@ -0,0 +1,222 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+const fakeBucketName = "bucket"
+
+// fakeGCS is a minimal GCS server for the tests.
+// It serves the JSON API for attributes and deletions and the XML API for reads of the objects in fakeBucketName.
+type fakeGCS struct {
+	mu      sync.Mutex
+	objects map[string]*fakeObject
+	// previous keeps the replaced generations, which are served to reads without a generation while staleReads is set.
+	previous   map[string]*fakeObject
+	staleReads bool
+	// reads counts the reads of the object contents.
+	reads int
+	// attrs counts the requests of the attributes by object name.
+	attrs map[string]int
+}
+
+type fakeObject struct {
+	generation  int64
+	content     []byte
+	contentType string
+}
+
+// newFakeGCS starts fakeGCS and returns the bucket handle of the client connected to it.
+func newFakeGCS(t *testing.T) (*fakeGCS, *storage.BucketHandle) {
+	f := &fakeGCS{
+		objects:  make(map[string]*fakeObject),
+		previous: make(map[string]*fakeObject),
+		attrs:    make(map[string]int),
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("STORAGE_EMULATOR_HOST", srv.URL)
+	client, err := storage.NewClient(context.Background())
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		client.Close()
+	})
+
+	return f, client.Bucket(fakeBucketName)
+}
+
+// put creates or replaces the object with the next generation.
+func (f *fakeGCS) put(name string, content string, contentType string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.store(name, []byte(content), contentType).generation
+}
+
+// store creates or replaces the object with the next generation while holding the lock.
+func (f *fakeGCS) store(name string, content []byte, contentType string) *fakeObject {
+	generation := int64(1)
+	if o, ok := f.objects[name]; ok {
+		f.previous[name] = o
+		generation = o.generation + 1
+	}
+	o := &fakeObject{generation: generation, content: content, contentType: contentType}
+	f.objects[name] = o
+	return o
+}
+
+// attrsCount returns the number of the requests of the attributes of the object.
+func (f *fakeGCS) attrsCount(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.attrs[name]
+}
+
+// readsCount returns the number of the reads of the object contents.
+func (f *fakeGCS) readsCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.reads
+}
+
+// get returns the object, or nil if missing.
+func (f *fakeGCS) get(name string) *fakeObject {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.objects[name]
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/"+fakeBucketName+"/o/"); name != r.URL.Path {
+		f.serveJSON(w, r, name)
+		return
+	}
+	if r.URL.Path == "/upload/storage/v1/b/"+fakeBucketName+"/o" {
+		f.serveUpload(w, r)
+		return
+	}
+	if name := strings.TrimPrefix(r.URL.Path, "/"+fakeBucketName+"/"); name != r.URL.Path {
+		f.serveRead(w, r, name)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (f *fakeGCS) serveJSON(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method == http.MethodGet {
+		f.attrs[name]++
+	}
+	o, ok := f.objects[name]
+	if !ok {
+		http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+		return
+	}
+	if v := r.URL.Query().Get("ifGenerationMatch"); len(v) > 0 && v != strconv.FormatInt(o.generation, 10) {
+		http.Error(w, `{"error": {"code": 412, "message": "precondition failed"}}`, http.StatusPreconditionFailed)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeFakeAttrs(w, name, o)
+	case http.MethodDelete:
+		delete(f.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeGCS) serveRead(w http.ResponseWriter, r *http.Request, name string) {
+	o, ok := f.objects[name]
+	if v := r.URL.Query().Get("generation"); len(v) > 0 {
+		ok = ok && v == strconv.FormatInt(o.generation, 10)
+	} else if p, stale := f.previous[name]; stale && f.staleReads {
+		o, ok = p, true
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f.reads++
+	w.Header().Set("Content-Type", o.contentType)
+	w.Header().Set("X-Goog-Generation", strconv.FormatInt(o.generation, 10))
+	w.Header().Set("X-Goog-Metageneration", "1")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(o.content))
+}
+
+// serveUpload stores the object of the multipart upload.
+func (f *fakeGCS) serveUpload(w http.ResponseWriter, r *http.Request) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+
+	var meta struct {
+		Name        string `json:"name"`
+		ContentType string `json:"contentType"`
+	}
+	part, err := mr.NextPart()
+	if err == nil {
+		err = json.NewDecoder(part).Decode(&meta)
+	}
+	var content []byte
+	if err == nil {
+		if part, err = mr.NextPart(); err == nil {
+			content, err = io.ReadAll(part)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if v := r.URL.Query().Get("ifGenerationMatch"); len(v) > 0 {
+		o, ok := f.objects[meta.Name]
+		if (v == "0" && ok) || (v != "0" && (!ok || v != strconv.FormatInt(o.generation, 10))) {
+			http.Error(w, `{"error": {"code": 412, "message": "precondition failed"}}`, http.StatusPreconditionFailed)
+			return
+		}
+	}
+
+	writeFakeAttrs(w, meta.Name, f.store(meta.Name, content, meta.ContentType))
+}
+
+// writeFakeAttrs writes the attributes of the object as the JSON API resource.
+func writeFakeAttrs(w http.ResponseWriter, name string, o *fakeObject) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"bucket":         fakeBucketName,
+		"name":           name,
+		"generation":     strconv.FormatInt(o.generation, 10),
+		"metageneration": "1",
+		"size":           strconv.Itoa(len(o.content)),
+		"contentType":    o.contentType,
+		"updated":        time.Unix(0, 0).UTC().Format(time.RFC3339),
+	})
+}
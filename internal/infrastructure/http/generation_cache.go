@@ -0,0 +1,121 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// generationCache caches the values loaded from objects and loads them again when their generation changes.
+// The generation is checked at the interval, and a missing object is cached as a nil value.
+type generationCache struct {
+	mu      sync.Mutex
+	entries map[string]*generationEntry
+	// name identifies the cache in the shared lookups.
+	name     string
+	interval func() time.Duration
+	// maxSize limits the size of the objects if positive.
+	maxSize int64
+	load    func(attrs *storage.ObjectAttrs, r io.Reader) (interface{}, error)
+}
+
+type generationEntry struct {
+	value      interface{}
+	generation int64
+	checked    time.Time
+}
+
+func newGenerationCache(name string, interval func() time.Duration, maxSize int64, load func(attrs *storage.ObjectAttrs, r io.Reader) (interface{}, error)) *generationCache {
+	return &generationCache{
+		entries:  make(map[string]*generationEntry),
+		name:     name,
+		interval: interval,
+		maxSize:  maxSize,
+		load:     load,
+	}
+}
+
+// get returns the value loaded from the object, or nil if the object is missing or invalid.
+func (c *generationCache) get(ctx context.Context, obj *storage.ObjectHandle) interface{} {
+	cacheKey := obj.BucketName() + "/" + obj.ObjectName()
+
+	c.mu.Lock()
+	e, ok := c.entries[cacheKey]
+	c.mu.Unlock()
+	if ok && time.Since(e.checked) < c.interval() {
+		return e.value
+	}
+
+	v, err := lookups.Do(ctx, c.name+":"+cacheKey, func(ctx context.Context) (interface{}, error) {
+		return c.reload(ctx, cacheKey, obj, e), nil
+	})
+	if err != nil {
+		// the request is canceled
+		if e != nil {
+			return e.value
+		}
+		return nil
+	}
+	return v
+}
+
+// reload loads the object if its generation differs from the entry.
+// The value of the entry is kept if the object cannot be loaded.
+func (c *generationCache) reload(ctx context.Context, cacheKey string, obj *storage.ObjectHandle, e *generationEntry) interface{} {
+	next := &generationEntry{checked: time.Now()}
+	if e != nil {
+		next.value, next.generation = e.value, e.generation
+	}
+	defer func() {
+		c.mu.Lock()
+		c.entries[cacheKey] = next
+		c.mu.Unlock()
+	}()
+
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			next.value, next.generation = nil, 0
+		} else {
+			log.Printf("http.generationCache.reload: failed to get attrs of %s: %v\n", cacheKey, err)
+		}
+		return next.value
+	}
+	if attrs.Generation == next.generation {
+		return next.value
+	}
+
+	v, err := c.loadObject(ctx, obj, attrs)
+	if err != nil {
+		log.Printf("http.generationCache.reload: %v\n", err)
+		return next.value
+	}
+
+	next.value, next.generation = v, attrs.Generation
+	return next.value
+}
+
+// loadObject reads the generation of the object and loads the value from it.
+func (c *generationCache) loadObject(ctx context.Context, obj *storage.ObjectHandle, attrs *storage.ObjectAttrs) (interface{}, error) {
+	if c.maxSize > 0 && attrs.Size > c.maxSize {
+		return nil, fmt.Errorf("object too large: %s: %d bytes", attrs.Name, attrs.Size)
+	}
+
+	r, err := obj.Generation(attrs.Generation).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", attrs.Name, err)
+	}
+	defer r.Close()
+
+	v, err := c.load(attrs, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", attrs.Name, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,43 @@
+package http
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerationCache_Get(t *testing.T) {
+	gcs, bucket := newFakeGCS(t)
+	gcs.put("errors/404.html", "v1", "text/html")
+	gcs.put("errors/large.html", "too large", "text/html")
+
+	c := newGenerationCache("test", func() time.Duration {
+		return time.Minute
+	}, 4, func(attrs *storage.ObjectAttrs, r io.Reader) (interface{}, error) {
+		b, err := io.ReadAll(r)
+		return string(b), err
+	})
+	get := func(name string) interface{} {
+		return c.get(context.Background(), bucket.Object(name))
+	}
+
+	assert.Equal(t, "v1", get("errors/404.html"))
+	assert.Nil(t, get("errors/missing.html"))
+	assert.Nil(t, get("errors/large.html"))
+
+	// cached within the interval, including missing objects
+	gcs.put("errors/404.html", "v2", "text/html")
+	assert.Equal(t, "v1", get("errors/404.html"))
+	assert.Nil(t, get("errors/missing.html"))
+	assert.Equal(t, 1, gcs.attrsCount("errors/404.html"))
+	assert.Equal(t, 1, gcs.attrsCount("errors/missing.html"))
+
+	// loaded again after the interval when the generation changes
+	c.entries[fakeBucketName+"/errors/404.html"].checked = time.Now().Add(-time.Hour)
+	assert.Equal(t, "v2", get("errors/404.html"))
+	assert.Equal(t, 2, gcs.attrsCount("errors/404.html"))
+}
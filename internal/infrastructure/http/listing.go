@@ -0,0 +1,227 @@
+package http
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+)
+
+var listingTemplate = template.Must(template.New("listing").Funcs(template.FuncMap{
+	"formatSize": formatSize,
+	"sortURL":    sortURL,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Index of {{.Path}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 1.5em 0.2em 0; text-align: left; }
+td.size { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<thead>
+<tr>
+{{if .Sortable}}<th><a href="{{sortURL "name" .Sort .Order}}">Name</a></th>
+<th><a href="{{sortURL "size" .Sort .Order}}">Size</a></th>
+<th><a href="{{sortURL "updated" .Sort .Order}}">Last Modified</a></th>
+{{else}}<th>Name</th>
+<th>Size</th>
+<th>Last Modified</th>
+{{end}}</tr>
+</thead>
+<tbody>
+{{if ne .Path "/"}}<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{end}}{{range .Entries}}<tr><td><a href="{{.URL}}">{{.Name}}</a></td><td class="size">{{if not .IsDir}}{{formatSize .Size}}{{end}}</td><td>{{if not .IsDir}}{{.Updated.UTC.Format "2006-01-02 15:04:05"}}{{end}}</td></tr>
+{{end}}</tbody>
+</table>
+{{if .NextPageToken}}<p><a href="?{{.NextQuery}}">Next page</a></p>{{end}}
+</body>
+</html>
+`))
+
+// listingEntry is an entry of the directory listing.
+type listingEntry struct {
+	Name    string
+	URL     string
+	Size    int64
+	Updated time.Time
+	IsDir   bool
+}
+
+// listingPage is the data of the directory listing template.
+type listingPage struct {
+	Path          string
+	Sort          string
+	Order         string
+	Sortable      bool
+	Entries       []listingEntry
+	NextPageToken string
+	NextQuery     string
+}
+
+// listingPrefix returns the prefix to be listed if the key is the main page of the requested directory.
+func listingPrefix(req *http.Request, s *site, key string) (string, bool) {
+	if !strings.HasSuffix(req.URL.Path, "/") {
+		return "", false
+	}
+
+	suffix := s.mainPageSuffix
+	if key != suffix && !strings.HasSuffix(key, "/"+suffix) {
+		return "", false
+	}
+
+	return strings.TrimSuffix(key, suffix), true
+}
+
+// serveDirectoryListing renders the HTML index of objects and sub-prefixes under the prefix.
+func serveDirectoryListing(w http.ResponseWriter, req *http.Request, s *site, prefix string) {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	q := &storage.Query{
+		Prefix:    prefix,
+		Delimiter: "/",
+	}
+	if err := q.SetAttrSelection([]string{"Name", "Size", "Updated"}); err != nil {
+		responseError(w, req, err)
+		return
+	}
+
+	var objs []*storage.ObjectAttrs
+	nextPageToken, err := iterator.NewPager(s.bucket.Objects(ctx, q), config.DirectoryListingPageSize(), query.Get("page")).NextPage(&objs)
+	if err != nil {
+		responseError(w, req, err)
+		return
+	}
+
+	entries := make([]listingEntry, 0, len(objs))
+	for _, o := range objs {
+		if len(o.Prefix) > 0 {
+			name := strings.TrimPrefix(o.Prefix, prefix)
+			entries = append(entries, listingEntry{
+				Name:  name,
+				URL:   (&url.URL{Path: name}).String(),
+				IsDir: true,
+			})
+			continue
+		}
+
+		// skip the directory placeholder object
+		if o.Name == prefix {
+			continue
+		}
+
+		name := strings.TrimPrefix(o.Name, prefix)
+		entries = append(entries, listingEntry{
+			Name:    name,
+			URL:     (&url.URL{Path: name}).String(),
+			Size:    o.Size,
+			Updated: o.Updated,
+		})
+	}
+
+	// a prefix without any objects does not exist
+	if len(entries) == 0 && prefix != s.prefix && query.Get("page") == "" {
+		serveNotFoundPage(w, req, s, prefix)
+		return
+	}
+
+	// GCS returns the entries in name order page by page, so sorting by another key is only
+	// correct when the whole listing fits in a single page
+	page := listingPage{
+		Path:          req.URL.Path,
+		Sortable:      len(nextPageToken) == 0 && query.Get("page") == "",
+		Entries:       entries,
+		NextPageToken: nextPageToken,
+	}
+	if page.Sortable {
+		page.Sort, page.Order = query.Get("sort"), query.Get("order")
+		sortListingEntries(page.Entries, page.Sort, page.Order)
+	}
+	if len(nextPageToken) > 0 {
+		next := url.Values{}
+		for k, v := range query {
+			next[k] = v
+		}
+		next.Del("sort")
+		next.Del("order")
+		next.Set("page", nextPageToken)
+		page.NextQuery = next.Encode()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := listingTemplate.Execute(w, page); err != nil {
+		log.Printf("http.serveDirectoryListing: failed to render listing: %v\n", err)
+	}
+}
+
+// sortListingEntries sorts the entries by the key (name, size, updated) and order (asc, desc).
+// Sub-prefixes are always listed before objects.
+func sortListingEntries(entries []listingEntry, key string, order string) {
+	less := func(a, b listingEntry) bool {
+		switch key {
+		case "size":
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		case "updated":
+			if !a.Updated.Equal(b.Updated) {
+				return a.Updated.Before(b.Updated)
+			}
+		}
+		return a.Name < b.Name
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		if order == "desc" {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+}
+
+// sortURL returns the query string to sort the listing by the key, toggling the order if already sorted by it.
+// It is only used for listings that fit in a single page.
+func sortURL(key string, currentKey string, currentOrder string) string {
+	q := url.Values{}
+	q.Set("sort", key)
+	if key == currentKey && currentOrder != "desc" {
+		q.Set("order", "desc")
+	}
+	return "?" + q.Encode()
+}
+
+// formatSize formats the byte size in a human-readable form.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
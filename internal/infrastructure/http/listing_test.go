@@ -0,0 +1,111 @@
+package http
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortListingEntries(t *testing.T) {
+	now := time.Now()
+	entries := func() []listingEntry {
+		return []listingEntry{
+			{Name: "b.txt", Size: 10, Updated: now.Add(-time.Hour)},
+			{Name: "sub/", IsDir: true},
+			{Name: "a.txt", Size: 30, Updated: now},
+			{Name: "c.txt", Size: 20, Updated: now.Add(-2 * time.Hour)},
+		}
+	}
+
+	testCases := []struct {
+		name  string
+		key   string
+		order string
+		want  []string
+	}{{
+		name: "Default",
+		want: []string{"sub/", "a.txt", "b.txt", "c.txt"},
+	}, {
+		name:  "Name desc",
+		key:   "name",
+		order: "desc",
+		want:  []string{"sub/", "c.txt", "b.txt", "a.txt"},
+	}, {
+		name: "Size asc",
+		key:  "size",
+		want: []string{"sub/", "b.txt", "c.txt", "a.txt"},
+	}, {
+		name:  "Updated desc",
+		key:   "updated",
+		order: "desc",
+		want:  []string{"sub/", "a.txt", "b.txt", "c.txt"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := entries()
+			sortListingEntries(got, tc.key, tc.order)
+
+			names := make([]string, 0, len(got))
+			for _, e := range got {
+				names = append(names, e.Name)
+			}
+			assert.Equal(t, tc.want, names)
+		})
+	}
+}
+
+func TestListingTemplate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		page     listingPage
+		wantSort bool
+		wantNext bool
+	}{{
+		name:     "Single page",
+		page:     listingPage{Path: "/docs/", Sortable: true},
+		wantSort: true,
+	}, {
+		name:     "Paginated",
+		page:     listingPage{Path: "/docs/", NextPageToken: "token", NextQuery: "page=token"},
+		wantNext: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			assert.NoError(t, listingTemplate.Execute(&b, tc.page))
+
+			assert.Equal(t, tc.wantSort, strings.Contains(b.String(), "?sort="))
+			assert.Equal(t, tc.wantNext, strings.Contains(b.String(), "Next page"))
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	testCases := []struct {
+		name string
+		arg  int64
+		want string
+	}{{
+		name: "Bytes",
+		arg:  512,
+		want: "512 B",
+	}, {
+		name: "Kibibytes",
+		arg:  1536,
+		want: "1.5 KiB",
+	}, {
+		name: "Gibibytes",
+		arg:  3 * 1024 * 1024 * 1024,
+		want: "3.0 GiB",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, formatSize(tc.arg))
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+)
+
+// forbiddenMetadataHeaders are never written from the custom metadata even if allowed by the configuration.
+// They are hop-by-hop, authentication or framing headers, or headers written from the object attributes.
+var forbiddenMetadataHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Authorization":       true,
+	"Www-Authenticate":    true,
+	"Set-Cookie":          true,
+	"Content-Length":      true,
+	"Content-Range":       true,
+	"Content-Type":        true,
+	"Content-Encoding":    true,
+	"Etag":                true,
+	"Last-Modified":       true,
+	"Cache-Control":       true,
+}
+
+// writeMetadataHeaders writes the custom metadata with the prefix (e.g. "header-link") as the response headers allowed by the configuration.
+func writeMetadataHeaders(w http.ResponseWriter, attrs *storage.ObjectAttrs) {
+	prefix := strings.ToLower(config.MetadataHeaderPrefix())
+	if len(prefix) == 0 {
+		return
+	}
+
+	for k, v := range attrs.Metadata {
+		if len(k) <= len(prefix) || strings.ToLower(k[:len(prefix)]) != prefix {
+			continue
+		}
+
+		name := http.CanonicalHeaderKey(k[len(prefix):])
+		if !isAllowedMetadataHeader(name) || strings.ContainsAny(v, "\r\n") {
+			continue
+		}
+		w.Header().Set(name, v)
+	}
+}
+
+// isAllowedMetadataHeader reports whether the header is allowed to be written from the custom metadata.
+func isAllowedMetadataHeader(name string) bool {
+	if forbiddenMetadataHeaders[name] {
+		return false
+	}
+	for _, h := range config.MetadataHeaders() {
+		if http.CanonicalHeaderKey(strings.TrimSpace(h)) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package http
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+)
+
+func TestWriteMetadataHeaders(t *testing.T) {
+	assert.NoError(t, config.LoadConf())
+
+	attrs := &storage.ObjectAttrs{
+		Metadata: map[string]string{
+			"header-content-security-policy": "default-src 'self'",
+			"Header-Link":                    "</app.js>; rel=preload; as=script",
+			"header-set-cookie":              "session=1",
+			"header-connection":              "close",
+			"header-x-custom":                "not allowed",
+			"header-x-robots-tag":            "noindex\r\nSet-Cookie: session=1",
+			"link":                           "without prefix",
+		},
+	}
+
+	w := httptest.NewRecorder()
+	writeMetadataHeaders(w, attrs)
+
+	assert.Equal(t, "default-src 'self'", w.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, "</app.js>; rel=preload; as=script", w.Header().Get("Link"))
+	assert.Len(t, w.Header(), 2)
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aplulu/gcsproxy/internal/util"
+)
+
+// CORSConfig is the configuration for the CORS middleware.
+type CORSConfig struct {
+	// AllowOrigins are the allowed origins. "*" allows all origins and "https://*.example.com" allows the subdomains.
+	AllowOrigins []string
+	AllowMethods []string
+	// AllowHeaders are the allowed request headers. "*" allows all requested headers.
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	// MaxAge is the seconds to cache the preflight response. Zero omits the header.
+	MaxAge  int
+	Skipper Skipper
+}
+
+// CORSWithConfig returns a middleware that handles Cross-Origin Resource Sharing.
+// Preflight requests are answered before the next handler so that they are not subject to authentication.
+func CORSWithConfig(conf CORSConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if conf.Skipper != nil && conf.Skipper(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			origin := r.Header.Get("Origin")
+			preflight := IsPreflight(r)
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if len(origin) == 0 || !conf.allowsOrigin(origin) {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if preflight {
+				method := r.Header.Get("Access-Control-Request-Method")
+				headers, ok := conf.allowedHeaders(r.Header.Get("Access-Control-Request-Headers"))
+				if !util.ContainsFold(conf.AllowMethods, method) || !ok {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+
+				conf.writeAllowOrigin(h, origin)
+				h.Set("Access-Control-Allow-Methods", strings.Join(conf.AllowMethods, ", "))
+				if len(headers) > 0 {
+					h.Set("Access-Control-Allow-Headers", headers)
+				}
+				if conf.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(conf.MaxAge))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			conf.writeAllowOrigin(h, origin)
+			if len(conf.ExposeHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(conf.ExposeHeaders, ", "))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsPreflight reports whether the request is a CORS preflight request.
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && len(r.Header.Get("Origin")) > 0 && len(r.Header.Get("Access-Control-Request-Method")) > 0
+}
+
+// allowsOrigin reports whether the origin is allowed.
+// "*" is ignored with credentials so that arbitrary origins are never reflected with Access-Control-Allow-Credentials.
+func (conf CORSConfig) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, o := range conf.AllowOrigins {
+		o = strings.ToLower(o)
+		if o == "*" {
+			if !conf.AllowCredentials {
+				return true
+			}
+			continue
+		}
+		if o == origin {
+			return true
+		}
+
+		// wildcard subdomains (e.g. "https://*.example.com")
+		if scheme, host, ok := strings.Cut(o, "://*."); ok {
+			prefix := scheme + "://"
+			if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin[len(prefix):], "."+host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowedHeaders returns the value of Access-Control-Allow-Headers for the requested headers.
+// It returns false if any of the requested headers is not allowed.
+func (conf CORSConfig) allowedHeaders(requested string) (string, bool) {
+	if len(strings.TrimSpace(requested)) == 0 {
+		return "", true
+	}
+	if util.ContainsFold(conf.AllowHeaders, "*") {
+		return requested, true
+	}
+
+	for _, name := range strings.Split(requested, ",") {
+		if !util.ContainsFold(conf.AllowHeaders, strings.TrimSpace(name)) {
+			return "", false
+		}
+	}
+	return strings.Join(conf.AllowHeaders, ", "), true
+}
+
+// writeAllowOrigin writes Access-Control-Allow-Origin, echoing the origin unless all origins are allowed without credentials.
+func (conf CORSConfig) writeAllowOrigin(h http.Header, origin string) {
+	if !conf.AllowCredentials && util.ContainsFold(conf.AllowOrigins, "*") {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	h.Set("Access-Control-Allow-Origin", origin)
+	if conf.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSWithConfig(t *testing.T) {
+	conf := CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowMethods:     []string{"GET", "HEAD"},
+		AllowHeaders:     []string{"Range"},
+		ExposeHeaders:    []string{"ETag"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+
+	testCases := []struct {
+		name       string
+		method     string
+		header     http.Header
+		wantStatus int
+		wantHeader http.Header
+	}{{
+		name:       "Simple request",
+		method:     http.MethodGet,
+		header:     http.Header{"Origin": {"https://app.example.com"}},
+		wantStatus: http.StatusOK,
+		wantHeader: http.Header{
+			"Vary":                             {"Origin"},
+			"Access-Control-Allow-Origin":      {"https://app.example.com"},
+			"Access-Control-Allow-Credentials": {"true"},
+			"Access-Control-Expose-Headers":    {"ETag"},
+		},
+	}, {
+		name:       "Wildcard subdomain",
+		method:     http.MethodGet,
+		header:     http.Header{"Origin": {"https://docs.example.org"}},
+		wantStatus: http.StatusOK,
+		wantHeader: http.Header{
+			"Vary":                             {"Origin"},
+			"Access-Control-Allow-Origin":      {"https://docs.example.org"},
+			"Access-Control-Allow-Credentials": {"true"},
+			"Access-Control-Expose-Headers":    {"ETag"},
+		},
+	}, {
+		name:       "Disallowed origin",
+		method:     http.MethodGet,
+		header:     http.Header{"Origin": {"https://evil.example.org.invalid"}},
+		wantStatus: http.StatusOK,
+		wantHeader: http.Header{"Vary": {"Origin"}},
+	}, {
+		name:   "Preflight",
+		method: http.MethodOptions,
+		header: http.Header{
+			"Origin":                         {"https://app.example.com"},
+			"Access-Control-Request-Method":  {"GET"},
+			"Access-Control-Request-Headers": {"range"},
+		},
+		wantStatus: http.StatusNoContent,
+		wantHeader: http.Header{
+			"Vary":                             {"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+			"Access-Control-Allow-Origin":      {"https://app.example.com"},
+			"Access-Control-Allow-Credentials": {"true"},
+			"Access-Control-Allow-Methods":     {"GET, HEAD"},
+			"Access-Control-Allow-Headers":     {"Range"},
+			"Access-Control-Max-Age":           {"600"},
+		},
+	}, {
+		name:   "Preflight with disallowed method",
+		method: http.MethodOptions,
+		header: http.Header{
+			"Origin":                        {"https://app.example.com"},
+			"Access-Control-Request-Method": {"DELETE"},
+		},
+		wantStatus: http.StatusNoContent,
+		wantHeader: http.Header{
+			"Vary": {"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := CORSWithConfig(conf)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tc.method, "/data.json", nil)
+			req.Header = tc.header
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			assert.Equal(t, tc.wantHeader, w.Header())
+		})
+	}
+}
+
+func TestCORSWithConfigWildcard(t *testing.T) {
+	testCases := []struct {
+		name             string
+		allowCredentials bool
+		wantHeader       http.Header
+	}{{
+		name:       "Without credentials",
+		wantHeader: http.Header{"Vary": {"Origin"}, "Access-Control-Allow-Origin": {"*"}},
+	}, {
+		name:             "With credentials",
+		allowCredentials: true,
+		wantHeader:       http.Header{"Vary": {"Origin"}},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := CORSWithConfig(CORSConfig{
+				AllowOrigins:     []string{"*"},
+				AllowMethods:     []string{"GET"},
+				AllowCredentials: tc.allowCredentials,
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/data.json", nil)
+			req.Header.Set("Origin", "https://evil.example.com")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantHeader, w.Header())
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// HeaderRule modifies the response headers of the requests matching the path and the content type.
+type HeaderRule struct {
+	// Path matches the request path. Nil matches all paths.
+	Path *regexp.Regexp
+	// ContentType matches the media type of the response (e.g. "text/html" or "image/*"). Empty matches all responses.
+	ContentType string
+	Set         map[string]string
+	Append      map[string]string
+	Remove      []string
+}
+
+// HeaderRulesConfig is the configuration for the HeaderRules middleware.
+type HeaderRulesConfig struct {
+	Rules   []HeaderRule
+	Skipper Skipper
+}
+
+// HeaderRulesWithConfig returns a middleware that modifies the response headers by the rules when the response is written.
+func HeaderRulesWithConfig(conf HeaderRulesConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(conf.Rules) == 0 || (conf.Skipper != nil && conf.Skipper(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(&headerRulesResponseWriter{
+				ResponseWriter: w,
+				path:           r.URL.Path,
+				rules:          conf.Rules,
+			}, r)
+		})
+	}
+}
+
+// applyHeaderRules modifies the headers by the rules matching the path and the content type of the headers.
+func applyHeaderRules(h http.Header, p string, rules []HeaderRule) {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+
+	for _, rule := range rules {
+		if rule.Path != nil && !rule.Path.MatchString(p) {
+			continue
+		}
+		if !matchMediaType(rule.ContentType, mediaType) {
+			continue
+		}
+
+		for k, v := range rule.Set {
+			h.Set(k, v)
+		}
+		for k, v := range rule.Append {
+			h.Add(k, v)
+		}
+		for _, k := range rule.Remove {
+			h.Del(k)
+		}
+	}
+}
+
+// matchMediaType reports whether the media type matches the pattern, which may end with "/*".
+func matchMediaType(pattern string, mediaType string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mediaType, strings.ToLower(pattern[:len(pattern)-1]))
+	}
+	return strings.EqualFold(pattern, mediaType)
+}
+
+// headerRulesResponseWriter applies the header rules just before the headers are written.
+type headerRulesResponseWriter struct {
+	http.ResponseWriter
+	path        string
+	rules       []HeaderRule
+	wroteHeader bool
+}
+
+func (w *headerRulesResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code >= http.StatusOK {
+		w.wroteHeader = true
+		applyHeaderRules(w.Header(), w.path, w.rules)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *headerRulesResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *headerRulesResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (w *headerRulesResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderRulesWithConfig(t *testing.T) {
+	rules := []HeaderRule{{
+		Set: map[string]string{"Strict-Transport-Security": "max-age=31536000"},
+	}, {
+		Path:        regexp.MustCompile(`^/app/`),
+		ContentType: "text/html",
+		Set:         map[string]string{"Content-Security-Policy": "default-src 'self'"},
+	}, {
+		ContentType: "image/*",
+		Append:      map[string]string{"Vary": "Accept"},
+		Remove:      []string{"X-Powered-By"},
+	}}
+
+	testCases := []struct {
+		name        string
+		path        string
+		contentType string
+		status      int
+		want        http.Header
+	}{{
+		name:        "HTML in the path",
+		path:        "/app/index.html",
+		contentType: "text/html; charset=utf-8",
+		status:      http.StatusOK,
+		want: http.Header{
+			"Content-Type":              {"text/html; charset=utf-8"},
+			"Strict-Transport-Security": {"max-age=31536000"},
+			"Content-Security-Policy":   {"default-src 'self'"},
+			"X-Powered-By":              {"gcsproxy"},
+			"Vary":                      {"Accept-Encoding"},
+		},
+	}, {
+		name:        "HTML outside the path",
+		path:        "/index.html",
+		contentType: "text/html",
+		status:      http.StatusOK,
+		want: http.Header{
+			"Content-Type":              {"text/html"},
+			"Strict-Transport-Security": {"max-age=31536000"},
+			"X-Powered-By":              {"gcsproxy"},
+			"Vary":                      {"Accept-Encoding"},
+		},
+	}, {
+		name:        "Image error",
+		path:        "/app/logo.png",
+		contentType: "image/png",
+		status:      http.StatusNotFound,
+		want: http.Header{
+			"Content-Type":              {"image/png"},
+			"Strict-Transport-Security": {"max-age=31536000"},
+			"Vary":                      {"Accept-Encoding", "Accept"},
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := HeaderRulesWithConfig(HeaderRulesConfig{Rules: rules})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.Header().Set("X-Powered-By", "gcsproxy")
+				w.Header().Set("Vary", "Accept-Encoding")
+				w.WriteHeader(tc.status)
+			}))
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.want, w.Header())
+		})
+	}
+}
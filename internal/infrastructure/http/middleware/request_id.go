@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// maxRequestIDLength is the maximum length of the request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+// RequestIDConfig is the configuration for the RequestID middleware.
+type RequestIDConfig struct {
+	// Header is the request and response header of the request ID.
+	Header  string
+	Skipper Skipper
+}
+
+// RequestIDWithConfig returns a middleware that sets the request ID to the request and response headers.
+// The request ID from the client or the trace ID of X-Cloud-Trace-Context is kept if valid, otherwise a random ID is generated.
+func RequestIDWithConfig(conf RequestIDConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if conf.Skipper != nil && conf.Skipper(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id := r.Header.Get(conf.Header)
+			if !isValidRequestID(id) {
+				id, _, _ = strings.Cut(r.Header.Get("X-Cloud-Trace-Context"), "/")
+			}
+			if !isValidRequestID(id) {
+				id = newRequestID()
+			}
+
+			r.Header.Set(conf.Header, id)
+			w.Header().Set(conf.Header, id)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isValidRequestID reports whether the request ID is not empty and consists of safe characters.
+func isValidRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDWithConfig(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{{
+		name:   "Client request ID",
+		header: http.Header{"X-Request-Id": {"abc-123"}},
+		want:   "abc-123",
+	}, {
+		name:   "Trace ID",
+		header: http.Header{"X-Cloud-Trace-Context": {"105445aa7843bc8bf206b12000100000/1;o=1"}},
+		want:   "105445aa7843bc8bf206b12000100000",
+	}, {
+		name: "Invalid client request ID",
+		header: http.Header{
+			"X-Request-Id":          {"<script>"},
+			"X-Cloud-Trace-Context": {"105445aa7843bc8bf206b12000100000/1;o=1"},
+		},
+		want: "105445aa7843bc8bf206b12000100000",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			h := RequestIDWithConfig(RequestIDConfig{Header: "X-Request-Id"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("X-Request-Id")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header = tc.header
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.want, rec.Header().Get("X-Request-Id"))
+		})
+	}
+
+	t.Run("Generated", func(t *testing.T) {
+		var got string
+		h := RequestIDWithConfig(RequestIDConfig{Header: "X-Request-Id"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header.Get("X-Request-Id")
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Len(t, got, 32)
+		assert.Equal(t, got, rec.Header().Get("X-Request-Id"))
+	})
+}
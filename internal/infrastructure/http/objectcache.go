@@ -0,0 +1,372 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+	"github.com/aplulu/gcsproxy/pkg/diskcache"
+	"github.com/aplulu/gcsproxy/pkg/lrucache"
+	"github.com/aplulu/gcsproxy/pkg/singleflight"
+)
+
+const (
+	// attrsCacheEntrySize is the approximate memory used by the cached object attributes besides the key.
+	attrsCacheEntrySize = 1024
+)
+
+// objectCacheStats exports the hit and miss counters of the object cache.
+var objectCacheStats = expvar.NewMap("object_cache")
+
+// objectCache caches object attributes, missing objects and small object contents in memory, and large object contents on disk.
+// A nil objectCache reads through to GCS.
+type objectCache struct {
+	cache             *lrucache.Cache
+	maxObjectSize     int64
+	disk              *diskcache.Cache
+	diskMinObjectSize int64
+	diskMaxObjectSize int64
+
+	fillsMu sync.Mutex
+	fills   map[string]*diskFill
+}
+
+// lookups coalesces the concurrent lookups and cache fills of the same object.
+var lookups singleflight.Group
+
+// cachedObjects is the object cache of the server, created by RunServer if enabled.
+var cachedObjects *objectCache
+
+// newObjectCache creates objectCache from the configuration, or returns nil if disabled.
+func newObjectCache() (*objectCache, error) {
+	if config.ObjectCacheMaxMemory() <= 0 && len(config.DiskCacheDir()) == 0 {
+		return nil, nil
+	}
+
+	c := &objectCache{}
+	if config.ObjectCacheMaxMemory() > 0 {
+		c.cache = lrucache.New(config.ObjectCacheMaxMemory(), time.Duration(config.ObjectCacheTTL())*time.Second)
+		c.maxObjectSize = config.ObjectCacheMaxObjectSize()
+		c.cache.OnEvict = func(key string, value interface{}) {
+			objectCacheStats.Add("evictions", 1)
+		}
+		objectCacheStats.Set("entries", expvar.Func(func() interface{} {
+			return c.cache.Len()
+		}))
+		objectCacheStats.Set("bytes", expvar.Func(func() interface{} {
+			return c.cache.Size()
+		}))
+	}
+
+	if len(config.DiskCacheDir()) > 0 {
+		disk, err := diskcache.New(config.DiskCacheDir(), config.DiskCacheMaxSize())
+		if err != nil {
+			return nil, fmt.Errorf("http.newObjectCache: failed to create disk cache: %w", err)
+		}
+		disk.OnEvict = func(key string) {
+			objectCacheStats.Add("disk_evictions", 1)
+		}
+		c.disk = disk
+		c.fills = make(map[string]*diskFill)
+		c.diskMinObjectSize = config.DiskCacheMinObjectSize()
+		c.diskMaxObjectSize = config.DiskCacheMaxSize()
+		objectCacheStats.Set("disk_bytes", expvar.Func(func() interface{} {
+			return disk.Size()
+		}))
+	}
+
+	return c, nil
+}
+
+// attrsCacheKey returns the cache key of the object attributes.
+func attrsCacheKey(bucket string, key string) string {
+	return "attrs:" + bucket + "/" + key
+}
+
+// bodyCacheKey returns the cache key of the object contents of the generation.
+func bodyCacheKey(attrs *storage.ObjectAttrs) string {
+	return "body:" + attrs.Bucket + "/" + attrs.Name + "#" + strconv.FormatInt(attrs.Generation, 10)
+}
+
+// attrs returns the attributes of the object. Missing objects are cached as storage.ErrObjectNotExist.
+func (c *objectCache) attrs(ctx context.Context, obj *storage.ObjectHandle) (*storage.ObjectAttrs, error) {
+	if c == nil || c.cache == nil {
+		return lookupAttrs(ctx, obj)
+	}
+
+	cacheKey := attrsCacheKey(obj.BucketName(), obj.ObjectName())
+	if v, ok := c.cache.Get(cacheKey); ok {
+		attrs := v.(*storage.ObjectAttrs)
+		if attrs == nil {
+			objectCacheStats.Add("negative_hits", 1)
+			return nil, storage.ErrObjectNotExist
+		}
+		objectCacheStats.Add("attrs_hits", 1)
+		return attrs, nil
+	}
+	objectCacheStats.Add("attrs_misses", 1)
+
+	attrs, err := lookupAttrs(ctx, obj)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			c.cache.Set(cacheKey, (*storage.ObjectAttrs)(nil), int64(attrsCacheEntrySize+len(cacheKey)))
+		}
+		return nil, err
+	}
+
+	c.cache.Set(cacheKey, attrs, int64(attrsCacheEntrySize+len(cacheKey)))
+	return attrs, nil
+}
+
+// lookupAttrs returns the attributes of the object, sharing one lookup among the concurrent requests for the object.
+func lookupAttrs(ctx context.Context, obj *storage.ObjectHandle) (*storage.ObjectAttrs, error) {
+	v, err := lookups.Do(ctx, attrsCacheKey(obj.BucketName(), obj.ObjectName()), func(ctx context.Context) (interface{}, error) {
+		return obj.Attrs(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*storage.ObjectAttrs), nil
+}
+
+// newReader returns the reader of the object contents of the generation in the attributes.
+// Objects up to the maximum object size are read into memory, and large objects are written to disk while being read.
+func (c *objectCache) newReader(ctx context.Context, obj *storage.ObjectHandle, attrs *storage.ObjectAttrs) (io.ReadCloser, error) {
+	if c == nil {
+		return obj.NewReader(ctx)
+	}
+	if c.cache == nil || attrs.Size > c.maxObjectSize {
+		return c.newDiskReader(ctx, obj, attrs)
+	}
+
+	cacheKey := bodyCacheKey(attrs)
+	if v, ok := c.cache.Get(cacheKey); ok {
+		objectCacheStats.Add("body_hits", 1)
+		return io.NopCloser(bytes.NewReader(v.([]byte))), nil
+	}
+	objectCacheStats.Add("body_misses", 1)
+
+	// concurrent misses share one read of the object
+	v, err := lookups.Do(ctx, cacheKey, func(ctx context.Context) (interface{}, error) {
+		r, err := obj.Generation(attrs.Generation).NewReader(ctx)
+		if err != nil {
+			// the cached attributes may refer to the replaced generation
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				c.invalidate(obj)
+			}
+			return nil, err
+		}
+		defer r.Close()
+
+		body, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("http.objectCache.newReader: failed to read object: %w", err)
+		}
+		if int64(len(body)) <= c.maxObjectSize {
+			c.cache.Set(cacheKey, body, int64(len(body)+len(cacheKey)))
+		}
+		return body, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(v.([]byte))), nil
+}
+
+// newDiskReader returns the reader of the object contents of the generation from disk.
+// On a miss, the reader follows the file being written from GCS, which is committed once read to the end.
+// Objects larger than the disk cache are read from GCS without being written to disk.
+func (c *objectCache) newDiskReader(ctx context.Context, obj *storage.ObjectHandle, attrs *storage.ObjectAttrs) (io.ReadCloser, error) {
+	if c.disk == nil || attrs.Size < c.diskMinObjectSize || attrs.Size > c.diskMaxObjectSize {
+		return obj.NewReader(ctx)
+	}
+
+	cacheKey := bodyCacheKey(attrs)
+	if f, ok := c.disk.Open(cacheKey); ok {
+		objectCacheStats.Add("disk_hits", 1)
+		return f, nil
+	}
+	objectCacheStats.Add("disk_misses", 1)
+
+	if r, ok := c.joinDiskFill(ctx, obj, attrs, cacheKey); ok {
+		// wait for the object to be opened so that the errors are returned before the response headers are written
+		if err := r.fill.waitOpened(ctx); err != nil {
+			r.Close()
+			return nil, err
+		}
+		return r, nil
+	}
+
+	// committed in the meantime or the cache is not writable
+	if f, ok := c.disk.Open(cacheKey); ok {
+		return f, nil
+	}
+	return obj.NewReader(ctx)
+}
+
+// joinDiskFill returns the reader following the file written by the fill of the object contents, starting the fill if none is in flight.
+// Concurrent misses share one read of the object.
+func (c *objectCache) joinDiskFill(ctx context.Context, obj *storage.ObjectHandle, attrs *storage.ObjectAttrs, cacheKey string) (*diskFillReader, bool) {
+	c.fillsMu.Lock()
+	defer c.fillsMu.Unlock()
+
+	fill, ok := c.fills[cacheKey]
+	if !ok {
+		cw, ok := c.disk.Create(cacheKey)
+		if !ok {
+			return nil, false
+		}
+
+		fillCtx, cancel := context.WithCancel(context.Background())
+		fill = &diskFill{cw: cw, cancel: cancel, opened: make(chan struct{})}
+		c.fills[cacheKey] = fill
+		go c.fillDisk(fillCtx, cacheKey, fill, obj.Generation(attrs.Generation), attrs.Size)
+	}
+
+	r, err := fill.cw.NewReader(ctx)
+	if err != nil {
+		return nil, false
+	}
+	fill.readers++
+
+	return &diskFillReader{Reader: r, c: c, key: cacheKey, fill: fill}, true
+}
+
+// fillDisk writes the object contents to the disk cache.
+func (c *objectCache) fillDisk(ctx context.Context, cacheKey string, fill *diskFill, obj *storage.ObjectHandle, size int64) {
+	defer func() {
+		fill.cancel()
+
+		c.fillsMu.Lock()
+		c.forgetFill(cacheKey, fill)
+		c.fillsMu.Unlock()
+	}()
+
+	r, err := obj.NewReader(ctx)
+	fill.openErr = err
+	close(fill.opened)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			c.invalidate(obj)
+		}
+		if !errors.Is(err, context.Canceled) {
+			log.Printf("http.objectCache.fillDisk: failed to open object: %v\n", err)
+		}
+		fill.cw.Abort()
+		return
+	}
+	defer r.Close()
+
+	n, err := io.Copy(fill.cw, r)
+	if err != nil || n != size {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("http.objectCache.fillDisk: failed to copy object: %v\n", err)
+		}
+		fill.cw.Abort()
+		return
+	}
+
+	if err := fill.cw.Commit(); err != nil {
+		log.Printf("http.objectCache.fillDisk: failed to commit cache: %v\n", err)
+	}
+}
+
+// forgetFill removes the fill so that later misses start a new one.
+func (c *objectCache) forgetFill(cacheKey string, fill *diskFill) {
+	if c.fills[cacheKey] == fill {
+		delete(c.fills, cacheKey)
+	}
+}
+
+// newRangeReader returns the reader of the byte range of the object contents, served from disk if cached.
+func (c *objectCache) newRangeReader(ctx context.Context, obj *storage.ObjectHandle, attrs *storage.ObjectAttrs, offset int64, length int64) (io.ReadCloser, error) {
+	if c != nil && c.disk != nil && attrs.Size >= c.diskMinObjectSize {
+		if f, ok := c.disk.Open(bodyCacheKey(attrs)); ok {
+			objectCacheStats.Add("disk_hits", 1)
+			return &sectionReadCloser{SectionReader: io.NewSectionReader(f, offset, length), f: f}, nil
+		}
+	}
+
+	return obj.NewRangeReader(ctx, offset, length)
+}
+
+// invalidate removes the cached attributes of the object.
+func (c *objectCache) invalidate(obj *storage.ObjectHandle) {
+	if c == nil || c.cache == nil {
+		return
+	}
+
+	c.cache.Delete(attrsCacheKey(obj.BucketName(), obj.ObjectName()))
+}
+
+// diskFill is the write of the object contents to the disk cache shared by the concurrent requests.
+type diskFill struct {
+	cw      *diskcache.Writer
+	readers int
+	cancel  context.CancelFunc
+	// opened is closed once the object is opened, with openErr set if it failed.
+	opened  chan struct{}
+	openErr error
+}
+
+// waitOpened waits for the object to be opened and returns the error of opening it.
+func (f *diskFill) waitOpened(ctx context.Context) error {
+	select {
+	case <-f.opened:
+		return f.openErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// diskFillReader follows the file written by the diskFill.
+// The fill is canceled once all readers are closed before it completes.
+type diskFillReader struct {
+	*diskcache.Reader
+	c    *objectCache
+	key  string
+	fill *diskFill
+}
+
+func (r *diskFillReader) Close() error {
+	err := r.Reader.Close()
+
+	r.c.fillsMu.Lock()
+	defer r.c.fillsMu.Unlock()
+
+	r.fill.readers--
+	if r.fill.readers == 0 {
+		r.fill.cancel()
+		r.c.forgetFill(r.key, r.fill)
+	}
+	return err
+}
+
+// sectionReadCloser reads the section of the cached file.
+type sectionReadCloser struct {
+	*io.SectionReader
+	f *os.File
+}
+
+func (r *sectionReadCloser) Close() error {
+	return r.f.Close()
+}
+
+// serveObjectCacheStats serves the counters of the object cache as JSON.
+func serveObjectCacheStats(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write([]byte(objectCacheStats.String()))
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+)
+
+func TestServeObject_OutdatedCachedAttrs(t *testing.T) {
+	testCases := []struct {
+		name       string
+		oldContent string
+		newContent string
+	}{{
+		name:       "Memory",
+		oldContent: "old",
+		newContent: "new",
+	}, {
+		name:       "Disk",
+		oldContent: "old contents",
+		newContent: "new contents, longer",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("OBJECT_CACHE_MAX_MEMORY", "1048576")
+			t.Setenv("OBJECT_CACHE_MAX_OBJECT_SIZE", "4")
+			t.Setenv("DISK_CACHE_DIR", t.TempDir())
+			t.Setenv("DISK_CACHE_MIN_OBJECT_SIZE", "5")
+			assert.NoError(t, config.LoadConf())
+
+			c, err := newObjectCache()
+			assert.NoError(t, err)
+			cachedObjects = c
+			t.Cleanup(func() {
+				cachedObjects = nil
+			})
+
+			gcs, bucket := newFakeGCS(t)
+			gcs.put("a.txt", tc.oldContent, "text/plain")
+			_, err = cachedObjects.attrs(context.Background(), bucket.Object("a.txt"))
+			assert.NoError(t, err)
+
+			// the object is replaced while its attributes are cached
+			gcs.put("a.txt", tc.newContent, "text/plain")
+
+			w := httptest.NewRecorder()
+			serveObject(w, httptest.NewRequest(http.MethodGet, "/a.txt", nil), &site{bucket: bucket}, "a.txt", http.StatusOK)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tc.newContent, w.Body.String())
+		})
+	}
+}
+
+func TestServeObject_OutdatedCachedAttrsRange(t *testing.T) {
+	t.Setenv("OBJECT_CACHE_MAX_MEMORY", "1048576")
+	assert.NoError(t, config.LoadConf())
+
+	c, err := newObjectCache()
+	assert.NoError(t, err)
+	cachedObjects = c
+	t.Cleanup(func() {
+		cachedObjects = nil
+	})
+
+	gcs, bucket := newFakeGCS(t)
+	gcs.put("a.txt", "old contents", "text/plain")
+	_, err = cachedObjects.attrs(context.Background(), bucket.Object("a.txt"))
+	assert.NoError(t, err)
+	gcs.put("a.txt", "new contents", "text/plain")
+
+	req := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+	req.Header.Set("Range", "bytes=0-2")
+	w := httptest.NewRecorder()
+	serveObject(w, req, &site{bucket: bucket}, "a.txt", http.StatusOK)
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "new", w.Body.String())
+}
+
+func TestServeObject_DiskCacheMaxSize(t *testing.T) {
+	testCases := []struct {
+		name       string
+		content    string
+		wantCached bool
+	}{{
+		name:       "Fits in the disk cache",
+		content:    "contents",
+		wantCached: true,
+	}, {
+		name:    "Larger than the disk cache",
+		content: "contents larger than the disk cache",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("DISK_CACHE_DIR", dir)
+			t.Setenv("DISK_CACHE_MAX_SIZE", "16")
+			t.Setenv("DISK_CACHE_MIN_OBJECT_SIZE", "1")
+			assert.NoError(t, config.LoadConf())
+
+			c, err := newObjectCache()
+			assert.NoError(t, err)
+			cachedObjects = c
+			t.Cleanup(func() {
+				cachedObjects = nil
+			})
+
+			gcs, bucket := newFakeGCS(t)
+			gcs.put("a.txt", tc.content, "text/plain")
+			misses := diskMisses()
+
+			w := httptest.NewRecorder()
+			serveObject(w, httptest.NewRequest(http.MethodGet, "/a.txt", nil), &site{bucket: bucket}, "a.txt", http.StatusOK)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tc.content, w.Body.String())
+			// only objects fitting in the disk cache are written to it
+			assert.Equal(t, tc.wantCached, diskMisses() > misses)
+		})
+	}
+}
+
+// diskMisses returns the number of the disk cache misses, which start the writes to the disk cache.
+func diskMisses() int64 {
+	v, ok := objectCacheStats.Get("disk_misses").(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return v.Value()
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+	"github.com/aplulu/gcsproxy/internal/util"
+	"github.com/aplulu/gcsproxy/pkg/lrucache"
+)
+
+const (
+	siblingCacheMaxEntries = 10000
+)
+
+// precompressedExtensions maps content codings to the file extensions of pre-compressed sibling objects.
+var precompressedExtensions = map[string]string{
+	"br":   ".br",
+	"gzip": ".gz",
+	"zstd": ".zst",
+}
+
+// siblingCache caches the attributes of pre-compressed sibling objects, or nil for missing siblings.
+// A nil siblingCache is disabled.
+type siblingCache struct {
+	cache *lrucache.Cache
+}
+
+var siblings *siblingCache
+
+// newSiblingCache creates siblingCache from the configuration, or returns nil if disabled.
+func newSiblingCache() *siblingCache {
+	if len(config.PrecompressedEncodings()) == 0 || config.PrecompressedCacheTTL() <= 0 {
+		return nil
+	}
+	return &siblingCache{
+		cache: lrucache.New(siblingCacheMaxEntries, time.Duration(config.PrecompressedCacheTTL())*time.Second),
+	}
+}
+
+// attrs returns the attributes of the sibling object, looking them up if not cached.
+func (c *siblingCache) attrs(ctx context.Context, obj *storage.ObjectHandle) (*storage.ObjectAttrs, error) {
+	if c == nil {
+		return cachedObjects.attrs(ctx, obj)
+	}
+
+	cacheKey := obj.BucketName() + "/" + obj.ObjectName()
+	if v, ok := c.cache.Get(cacheKey); ok {
+		attrs := v.(*storage.ObjectAttrs)
+		if attrs == nil {
+			return nil, storage.ErrObjectNotExist
+		}
+		return attrs, nil
+	}
+
+	attrs, err := cachedObjects.attrs(ctx, obj)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			c.cache.Set(cacheKey, (*storage.ObjectAttrs)(nil), 1)
+		}
+		return nil, err
+	}
+	c.cache.Set(cacheKey, attrs, 1)
+	return attrs, nil
+}
+
+// invalidate removes the cached attributes of the sibling object.
+func (c *siblingCache) invalidate(obj *storage.ObjectHandle) {
+	if c == nil {
+		return
+	}
+
+	c.cache.Delete(obj.BucketName() + "/" + obj.ObjectName())
+}
+
+// findPrecompressed returns the pre-compressed sibling of the object accepted by the client.
+// The returned attributes keep the content type and the response headers of the original object.
+func findPrecompressed(req *http.Request, s *site, key string, attrs *storage.ObjectAttrs) (*storage.ObjectHandle, *storage.ObjectAttrs, bool) {
+	ctx := req.Context()
+
+	for _, encoding := range config.PrecompressedEncodings() {
+		ext, ok := precompressedExtensions[encoding]
+		if !ok || !util.AcceptsEncoding(req, encoding) {
+			continue
+		}
+
+		// serve the stored bytes as is even if the sibling has Content-Encoding metadata
+		obj := s.bucket.Object(key + ext).ReadCompressed(true)
+		siblingAttrs, err := siblings.attrs(ctx, obj)
+		if err != nil {
+			if !errors.Is(err, storage.ErrObjectNotExist) {
+				log.Printf("http.findPrecompressed: failed to get sibling attrs: %v\n", err)
+			}
+			continue
+		}
+
+		a := *siblingAttrs
+		a.ContentType = attrs.ContentType
+		a.ContentEncoding = encoding
+		a.ContentDisposition = attrs.ContentDisposition
+		a.ContentLanguage = attrs.ContentLanguage
+		a.CacheControl = attrs.CacheControl
+		a.Metadata = attrs.Metadata
+		return obj.Generation(a.Generation), &a, true
+	}
+
+	return nil, nil, false
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+)
+
+func TestServeObject_Precompressed(t *testing.T) {
+	t.Setenv("PRECOMPRESSED_ENCODINGS", "br,gzip")
+	assert.NoError(t, config.LoadConf())
+	siblings = newSiblingCache()
+	t.Cleanup(func() {
+		siblings = nil
+	})
+
+	gcs, bucket := newFakeGCS(t)
+	gcs.put("app.js", "console.log('hello');", "text/javascript")
+	gcs.put("app.js.br", "compressed", "application/x-brotli")
+	siblingAttrs, err := bucket.Object("app.js.br").Attrs(context.Background())
+	assert.NoError(t, err)
+
+	serve := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		serveObject(w, req, &site{bucket: bucket}, "app.js", http.StatusOK)
+		return w
+	}
+
+	w := serve("gzip, br")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "compressed", w.Body.String())
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/javascript", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(t, objectETag(siblingAttrs), w.Header().Get("ETag"))
+
+	// the sibling attributes are cached
+	attrsCount := gcs.attrsCount("app.js.br")
+	assert.Equal(t, "compressed", serve("br").Body.String())
+	assert.Equal(t, attrsCount, gcs.attrsCount("app.js.br"))
+
+	// missing siblings are cached too
+	w = serve("gzip")
+	assert.Equal(t, "console.log('hello');", w.Body.String())
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, 1, gcs.attrsCount("app.js.gz"))
+	serve("gzip")
+	assert.Equal(t, 1, gcs.attrsCount("app.js.gz"))
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/aplulu/gcsproxy/internal/domain/model"
+)
+
+// maxRanges is the maximum number of ranges in a request, as each range is read from GCS separately.
+const maxRanges = 32
+
+// httpRange specifies the byte range to be sent to the client.
+type httpRange struct {
+	start  int64
+	length int64
+}
+
+// contentRange returns the value of the Content-Range header.
+func (r httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// mimeHeader returns the part header of the multipart/byteranges response.
+func (r httpRange) mimeHeader(contentType string, size int64) textproto.MIMEHeader {
+	h := textproto.MIMEHeader{
+		"Content-Range": {r.contentRange(size)},
+	}
+	if len(contentType) > 0 {
+		h.Set("Content-Type", contentType)
+	}
+	return h
+}
+
+// parseRange parses a Range header string as per RFC 7233.
+// It returns nil if the ranges exceed the size of the content or maxRanges, so that the whole content is served instead.
+func parseRange(s string, size int64) ([]httpRange, error) {
+	const b = "bytes="
+	if !strings.HasPrefix(s, b) {
+		return nil, fmt.Errorf("http.parseRange: unsupported unit: %s: %w", s, model.ErrInvalidRange)
+	}
+
+	var ranges []httpRange
+	noOverlap := false
+	for _, ra := range strings.Split(s[len(b):], ",") {
+		ra = textproto.TrimString(ra)
+		if ra == "" {
+			continue
+		}
+
+		i := strings.Index(ra, "-")
+		if i < 0 {
+			return nil, fmt.Errorf("http.parseRange: invalid range: %s: %w", ra, model.ErrInvalidRange)
+		}
+		start, end := textproto.TrimString(ra[:i]), textproto.TrimString(ra[i+1:])
+
+		var r httpRange
+		if start == "" {
+			// suffix-byte-range-spec
+			if end == "" || end[0] == '-' {
+				return nil, fmt.Errorf("http.parseRange: invalid range: %s: %w", ra, model.ErrInvalidRange)
+			}
+			i, err := strconv.ParseInt(end, 10, 64)
+			if i < 0 || err != nil {
+				return nil, fmt.Errorf("http.parseRange: invalid range: %s: %w", ra, model.ErrInvalidRange)
+			}
+			if i == 0 {
+				noOverlap = true
+				continue
+			}
+			if i > size {
+				i = size
+			}
+			r.start = size - i
+			r.length = size - r.start
+		} else {
+			i, err := strconv.ParseInt(start, 10, 64)
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("http.parseRange: invalid range: %s: %w", ra, model.ErrInvalidRange)
+			}
+			if i >= size {
+				noOverlap = true
+				continue
+			}
+			r.start = i
+			if end == "" {
+				r.length = size - r.start
+			} else {
+				i, err := strconv.ParseInt(end, 10, 64)
+				if err != nil || r.start > i {
+					return nil, fmt.Errorf("http.parseRange: invalid range: %s: %w", ra, model.ErrInvalidRange)
+				}
+				if i >= size {
+					i = size - 1
+				}
+				r.length = i - r.start + 1
+			}
+		}
+		ranges = append(ranges, r)
+	}
+
+	if noOverlap && len(ranges) == 0 {
+		return nil, fmt.Errorf("http.parseRange: failed to overlap: %s: %w", s, model.ErrRangeNotSatisfiable)
+	}
+
+	if len(ranges) > maxRanges {
+		return nil, nil
+	}
+
+	var total int64
+	for _, r := range ranges {
+		total += r.length
+	}
+	if total > size {
+		return nil, nil
+	}
+
+	return ranges, nil
+}
+
+// checkIfRange reports whether the Range header should be honored according to the If-Range header.
+func checkIfRange(req *http.Request, attrs *storage.ObjectAttrs) bool {
+	ir := req.Header.Get("If-Range")
+	if ir == "" {
+		return true
+	}
+
+	// entity-tag (strong comparison)
+	if strings.HasPrefix(ir, "\"") || strings.HasPrefix(ir, "W/") {
+		return ir == objectETag(attrs)
+	}
+
+	// HTTP-date
+	t, err := http.ParseTime(ir)
+	if err != nil {
+		return false
+	}
+	return attrs.Updated.Truncate(time.Second).Equal(t)
+}
@@ -0,0 +1,185 @@
+package http
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+	"github.com/aplulu/gcsproxy/internal/domain/model"
+)
+
+func TestParseRange(t *testing.T) {
+	testCases := []struct {
+		name    string
+		header  string
+		size    int64
+		want    []httpRange
+		wantErr error
+	}{{
+		name:   "Single range",
+		header: "bytes=0-99",
+		size:   1000,
+		want:   []httpRange{{start: 0, length: 100}},
+	}, {
+		name:   "Open ended range",
+		header: "bytes=900-",
+		size:   1000,
+		want:   []httpRange{{start: 900, length: 100}},
+	}, {
+		name:   "Suffix range",
+		header: "bytes=-100",
+		size:   1000,
+		want:   []httpRange{{start: 900, length: 100}},
+	}, {
+		name:   "End exceeds size",
+		header: "bytes=500-2000",
+		size:   1000,
+		want:   []httpRange{{start: 500, length: 500}},
+	}, {
+		name:   "Multiple ranges",
+		header: "bytes=0-9, 20-29",
+		size:   1000,
+		want:   []httpRange{{start: 0, length: 10}, {start: 20, length: 10}},
+	}, {
+		name:   "Ranges exceed size",
+		header: "bytes=0-,0-",
+		size:   1000,
+		want:   nil,
+	}, {
+		name:   "Too many ranges",
+		header: "bytes=" + strings.Repeat("0-0,", maxRanges) + "2-2",
+		size:   1000,
+		want:   nil,
+	}, {
+		name:    "Start exceeds size",
+		header:  "bytes=1000-",
+		size:    1000,
+		wantErr: model.ErrRangeNotSatisfiable,
+	}, {
+		name:    "Unsupported unit",
+		header:  "items=0-9",
+		size:    1000,
+		wantErr: model.ErrInvalidRange,
+	}, {
+		name:    "Invalid range",
+		header:  "bytes=10-5",
+		size:    1000,
+		wantErr: model.ErrInvalidRange,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseRange(tc.header, tc.size)
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServeObject_Range(t *testing.T) {
+	assert.NoError(t, config.LoadConf())
+
+	testCases := []struct {
+		name             string
+		header           http.Header
+		ifRange          func(etag string) string
+		wantStatus       int
+		wantBody         string
+		wantContentRange string
+		wantParts        []string
+	}{{
+		name:             "Single range",
+		header:           http.Header{"Range": {"bytes=0-3"}},
+		wantStatus:       http.StatusPartialContent,
+		wantBody:         "0123",
+		wantContentRange: "bytes 0-3/10",
+	}, {
+		name:       "Multiple ranges",
+		header:     http.Header{"Range": {"bytes=0-1,8-"}},
+		wantStatus: http.StatusPartialContent,
+		wantParts:  []string{"01", "89"},
+	}, {
+		name:             "Not satisfiable",
+		header:           http.Header{"Range": {"bytes=10-"}},
+		wantStatus:       http.StatusRequestedRangeNotSatisfiable,
+		wantContentRange: "bytes */10",
+	}, {
+		name:       "Too many ranges",
+		header:     http.Header{"Range": {"bytes=" + strings.Repeat("0-0,", maxRanges) + "2-2"}},
+		wantStatus: http.StatusOK,
+		wantBody:   "0123456789",
+	}, {
+		name:       "If-Range mismatch",
+		header:     http.Header{"Range": {"bytes=0-3"}},
+		ifRange:    func(etag string) string { return `"other"` },
+		wantStatus: http.StatusOK,
+		wantBody:   "0123456789",
+	}, {
+		name:             "If-Range match",
+		header:           http.Header{"Range": {"bytes=0-3"}},
+		ifRange:          func(etag string) string { return etag },
+		wantStatus:       http.StatusPartialContent,
+		wantBody:         "0123",
+		wantContentRange: "bytes 0-3/10",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcs, bucket := newFakeGCS(t)
+			gcs.put("a.txt", "0123456789", "text/plain")
+
+			req := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			if tc.ifRange != nil {
+				attrs, err := bucket.Object("a.txt").Attrs(context.Background())
+				assert.NoError(t, err)
+				req.Header.Set("If-Range", tc.ifRange(objectETag(attrs)))
+			}
+
+			w := httptest.NewRecorder()
+			serveObject(w, req, &site{bucket: bucket}, "a.txt", http.StatusOK)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			assert.Equal(t, tc.wantContentRange, w.Header().Get("Content-Range"))
+			if len(tc.wantParts) == 0 {
+				if len(tc.wantBody) > 0 {
+					assert.Equal(t, tc.wantBody, w.Body.String())
+				}
+				return
+			}
+
+			mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+			assert.NoError(t, err)
+			assert.Equal(t, "multipart/byteranges", mediaType)
+			mr := multipart.NewReader(w.Body, params["boundary"])
+			var parts []string
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				assert.NoError(t, err)
+				b, err := io.ReadAll(part)
+				assert.NoError(t, err)
+				assert.Equal(t, "text/plain", part.Header.Get("Content-Type"))
+				parts = append(parts, string(b))
+			}
+			assert.Equal(t, tc.wantParts, parts)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	redirectLocationMetadataKey = "gcsproxy-redirect-location"
+	redirectStatusMetadataKey   = "gcsproxy-redirect-status"
+)
+
+// redirectStatusCodes are the status codes allowed for the redirect objects.
+var redirectStatusCodes = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+}
+
+// objectRedirect returns the location and the status code of the redirect set in the custom metadata of the object.
+// Relative locations are resolved against the request path, and absolute locations must be http or https URLs.
+func objectRedirect(req *http.Request, attrs *storage.ObjectAttrs) (string, int, bool) {
+	location := strings.TrimSpace(metadataValue(attrs, redirectLocationMetadataKey))
+	if len(location) == 0 {
+		return "", 0, false
+	}
+
+	status := http.StatusMovedPermanently
+	if v := metadataValue(attrs, redirectStatusMetadataKey); len(v) > 0 {
+		code, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || !redirectStatusCodes[code] {
+			log.Printf("http.objectRedirect: invalid redirect status of %s: %s\n", attrs.Name, v)
+			return "", 0, false
+		}
+		status = code
+	}
+
+	// browsers treat backslashes as slashes, which may turn the location into a protocol-relative URL
+	u, err := url.Parse(location)
+	if err != nil || strings.Contains(location, "\\") {
+		log.Printf("http.objectRedirect: invalid redirect location of %s: %s\n", attrs.Name, location)
+		return "", 0, false
+	}
+	if u.IsAbs() {
+		if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			log.Printf("http.objectRedirect: invalid redirect location of %s: %s\n", attrs.Name, location)
+			return "", 0, false
+		}
+		return u.String(), status, true
+	}
+	if len(u.Host) > 0 {
+		log.Printf("http.objectRedirect: protocol-relative redirect location of %s: %s\n", attrs.Name, location)
+		return "", 0, false
+	}
+
+	location = (&url.URL{Path: req.URL.Path}).ResolveReference(u).String()
+	if !strings.HasPrefix(location, "/") || isProtocolRelative(location) {
+		log.Printf("http.objectRedirect: invalid redirect location of %s: %s\n", attrs.Name, location)
+		return "", 0, false
+	}
+	return location, status, true
+}
+
+// metadataValue returns the value of the custom metadata key, ignoring the case of the key.
+func metadataValue(attrs *storage.ObjectAttrs, key string) string {
+	if v, ok := attrs.Metadata[key]; ok {
+		return v
+	}
+	for k, v := range attrs.Metadata {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// isProtocolRelative reports whether browsers interpret the location as a protocol-relative URL.
+func isProtocolRelative(location string) bool {
+	return strings.HasPrefix(location, "//") || strings.HasPrefix(location, "/\\")
+}
+
+// localLocation collapses the leading slashes of the location on this host, so that it is not interpreted as a protocol-relative URL.
+func localLocation(location string) string {
+	if isProtocolRelative(location) {
+		return "/" + strings.TrimLeft(location, "/\\")
+	}
+	return location
+}
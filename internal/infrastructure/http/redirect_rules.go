@@ -0,0 +1,336 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+	"github.com/aplulu/gcsproxy/internal/util"
+)
+
+// redirectRule is the rule of the redirects file.
+// The status code 200 rewrites the request to the target internally, and 404 serves the target with 404 Not Found.
+type redirectRule struct {
+	from      []string
+	to        string
+	status    int
+	countries []string
+	languages []string
+	cookies   []string
+}
+
+// redirectRuleJSON is the rule of the redirects file in JSON (e.g. gcsproxy.json).
+type redirectRuleJSON struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status"`
+	// Force is accepted for compatibility, rules are always applied before the object lookup.
+	Force      bool `json:"force"`
+	Conditions struct {
+		Country  []string `json:"country"`
+		Language []string `json:"language"`
+		Cookie   []string `json:"cookie"`
+	} `json:"conditions"`
+}
+
+// redirectStatuses are the status codes allowed for the rules.
+var redirectStatuses = map[int]bool{
+	http.StatusOK:                true,
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusSeeOther:          true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+	http.StatusNotFound:          true,
+}
+
+// placeholderPattern matches the placeholders (e.g. ":splat" or ":slug") of the rule target.
+var placeholderPattern = regexp.MustCompile(`:[A-Za-z_][A-Za-z0-9_]*`)
+
+// newRedirectRule creates redirectRule, validating the source path and the status code.
+func newRedirectRule(from string, to string, status int) (redirectRule, error) {
+	if !strings.HasPrefix(from, "/") {
+		return redirectRule{}, fmt.Errorf("http.newRedirectRule: source must begin with /: %s", from)
+	}
+	if len(to) == 0 {
+		return redirectRule{}, fmt.Errorf("http.newRedirectRule: target is empty: %s", from)
+	}
+	if status == 0 {
+		status = http.StatusMovedPermanently
+	}
+	if !redirectStatuses[status] {
+		return redirectRule{}, fmt.Errorf("http.newRedirectRule: unsupported status: %d", status)
+	}
+
+	return redirectRule{
+		from:   splitPath(from),
+		to:     to,
+		status: status,
+	}, nil
+}
+
+// parseRedirectRules parses the rules in the Netlify _redirects format, one rule per line:
+//
+//	/from /to [status][!] [Country=us,ca] [Language=en,ja] [Cookie=name]
+//
+// Invalid lines are skipped with a log.
+func parseRedirectRules(r io.Reader) ([]redirectRule, error) {
+	var rules []redirectRule
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			log.Printf("http.parseRedirectRules: invalid rule at line %d: %s\n", n, line)
+			continue
+		}
+
+		status := 0
+		var conditions []string
+		for _, f := range fields[2:] {
+			if k, v, ok := strings.Cut(f, "="); ok {
+				conditions = append(conditions, k, v)
+				continue
+			}
+			// "!" forces the rule, which is the only behavior since rules are applied before the object lookup
+			code, err := strconv.Atoi(strings.TrimSuffix(f, "!"))
+			if err != nil {
+				status = -1
+				break
+			}
+			status = code
+		}
+		if status < 0 {
+			log.Printf("http.parseRedirectRules: invalid status at line %d: %s\n", n, line)
+			continue
+		}
+
+		rule, err := newRedirectRule(fields[0], fields[1], status)
+		if err != nil {
+			log.Printf("http.parseRedirectRules: invalid rule at line %d: %v\n", n, err)
+			continue
+		}
+		for i := 0; i < len(conditions); i += 2 {
+			values := strings.Split(conditions[i+1], ",")
+			switch strings.ToLower(conditions[i]) {
+			case "country":
+				rule.countries = values
+			case "language":
+				rule.languages = values
+			case "cookie":
+				rule.cookies = values
+			}
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("http.parseRedirectRules: failed to read rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// parseRedirectRulesJSON parses the rules in JSON: {"redirects": [{"from": "/old/*", "to": "/new/:splat", "status": 301}]}
+func parseRedirectRulesJSON(r io.Reader) ([]redirectRule, error) {
+	var file struct {
+		Redirects []redirectRuleJSON `json:"redirects"`
+	}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("http.parseRedirectRulesJSON: failed to decode rules: %w", err)
+	}
+
+	var rules []redirectRule
+	for i, rr := range file.Redirects {
+		rule, err := newRedirectRule(rr.From, rr.To, rr.Status)
+		if err != nil {
+			log.Printf("http.parseRedirectRulesJSON: invalid rule %d: %v\n", i, err)
+			continue
+		}
+		rule.countries = rr.Conditions.Country
+		rule.languages = rr.Conditions.Language
+		rule.cookies = rr.Conditions.Cookie
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// splitPath splits the path into the segments, ignoring the leading and trailing slashes.
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if len(p) == 0 {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// match returns the values of the placeholders if the path matches the rule.
+// "*" at the end of the source matches the rest of the path as ":splat".
+func (rule redirectRule) match(p string) (map[string]string, bool) {
+	segments := splitPath(p)
+	values := make(map[string]string)
+
+	for i, seg := range rule.from {
+		if seg == "*" && i == len(rule.from)-1 {
+			values["splat"] = strings.Join(segments[i:], "/")
+			return values, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		if strings.HasPrefix(seg, ":") {
+			values[seg[1:]] = segments[i]
+			continue
+		}
+		if seg != segments[i] {
+			return nil, false
+		}
+	}
+	if len(segments) != len(rule.from) {
+		return nil, false
+	}
+	return values, true
+}
+
+// matchConditions reports whether the request satisfies the country, language and cookie conditions of the rule.
+func (rule redirectRule) matchConditions(req *http.Request) bool {
+	if len(rule.countries) > 0 && !util.ContainsFold(rule.countries, req.Header.Get(config.RedirectsCountryHeader())) {
+		return false
+	}
+	if len(rule.languages) > 0 && !acceptsLanguage(req, rule.languages) {
+		return false
+	}
+	if len(rule.cookies) > 0 {
+		found := false
+		for _, name := range rule.cookies {
+			if _, err := req.Cookie(name); err == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// target returns the target of the rule with the placeholders replaced.
+// If escape is true, the values are escaped for the URL.
+func (rule redirectRule) target(values map[string]string, escape bool) string {
+	return placeholderPattern.ReplaceAllStringFunc(rule.to, func(s string) string {
+		v, ok := values[s[1:]]
+		if !ok {
+			return s
+		}
+		if escape {
+			segments := strings.Split(v, "/")
+			for i, seg := range segments {
+				segments[i] = url.PathEscape(seg)
+			}
+			v = strings.Join(segments, "/")
+		}
+		return v
+	})
+}
+
+// acceptsLanguage reports whether the Accept-Language header includes any of the languages or their primary subtags.
+func acceptsLanguage(req *http.Request, languages []string) bool {
+	for _, v := range req.Header.Values("Accept-Language") {
+		for _, part := range strings.Split(v, ",") {
+			tag, params, _ := strings.Cut(part, ";")
+			tag = strings.TrimSpace(tag)
+			if strings.ReplaceAll(strings.TrimSpace(params), " ", "") == "q=0" {
+				continue
+			}
+			primary, _, _ := strings.Cut(tag, "-")
+			for _, lang := range languages {
+				if strings.EqualFold(lang, tag) || strings.EqualFold(lang, primary) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// redirectRules caches the rules of the redirects files and reloads them when their generation changes.
+var redirectRules = newGenerationCache("redirects", func() time.Duration {
+	return time.Duration(config.RedirectsReloadInterval()) * time.Second
+}, 0, func(attrs *storage.ObjectAttrs, r io.Reader) (interface{}, error) {
+	if path.Ext(attrs.Name) == ".json" {
+		return parseRedirectRulesJSON(r)
+	}
+	return parseRedirectRules(r)
+})
+
+// siteRedirectRules returns the rules of the redirects file of the site, checking its generation at the reload interval.
+func siteRedirectRules(ctx context.Context, s *site) []redirectRule {
+	rules, _ := redirectRules.get(ctx, s.bucket.Object(s.prefix+config.RedirectsFile())).([]redirectRule)
+	return rules
+}
+
+// serveRedirectRules applies the first rule of the site matching the request path relative to the site.
+// It returns false if no rule matches.
+func serveRedirectRules(w http.ResponseWriter, req *http.Request, s *site, p string) bool {
+	if len(config.RedirectsFile()) == 0 {
+		return false
+	}
+
+	for _, rule := range siteRedirectRules(req.Context(), s) {
+		values, ok := rule.match(p)
+		if !ok || !rule.matchConditions(req) {
+			continue
+		}
+
+		if rule.status == http.StatusOK || rule.status == http.StatusNotFound {
+			target := rule.target(values, false)
+			if !strings.HasPrefix(target, "/") {
+				log.Printf("http.serveRedirectRules: unsupported rewrite target: %s\n", target)
+				continue
+			}
+			cleaned := path.Clean(target)
+			if strings.HasSuffix(target, "/") && cleaned != "/" {
+				cleaned += "/"
+			}
+			serveObject(w, req, s, s.mainPageKey(cleaned), rule.status)
+			return true
+		}
+
+		location := rule.target(values, true)
+		if strings.HasPrefix(location, "/") {
+			// the target is relative to the mount of the site
+			location = (&url.URL{Path: strings.TrimSuffix(req.URL.Path, p)}).EscapedPath() + location
+			location = localLocation(location)
+		} else if u, err := url.Parse(location); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			log.Printf("http.serveRedirectRules: invalid redirect target: %s\n", location)
+			continue
+		}
+		if len(req.URL.RawQuery) > 0 && !strings.Contains(location, "?") {
+			location += "?" + req.URL.RawQuery
+		}
+
+		http.Redirect(w, req, location, rule.status)
+		return true
+	}
+
+	return false
+}
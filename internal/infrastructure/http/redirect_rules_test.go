@@ -0,0 +1,195 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+)
+
+func TestParseRedirectRules(t *testing.T) {
+	rules, err := parseRedirectRules(strings.NewReader(`
+# comment
+/old /new
+/news/* /blog/:splat 302!
+/ja/* /ja/:splat 200 Language=ja Country=jp,us
+/beta/* /preview/:splat 307 Cookie=beta
+/invalid
+/bad /status abc
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []redirectRule{{
+		from:   []string{"old"},
+		to:     "/new",
+		status: http.StatusMovedPermanently,
+	}, {
+		from:   []string{"news", "*"},
+		to:     "/blog/:splat",
+		status: http.StatusFound,
+	}, {
+		from:      []string{"ja", "*"},
+		to:        "/ja/:splat",
+		status:    http.StatusOK,
+		countries: []string{"jp", "us"},
+		languages: []string{"ja"},
+	}, {
+		from:    []string{"beta", "*"},
+		to:      "/preview/:splat",
+		status:  http.StatusTemporaryRedirect,
+		cookies: []string{"beta"},
+	}}, rules)
+}
+
+func TestParseRedirectRulesJSON(t *testing.T) {
+	rules, err := parseRedirectRulesJSON(strings.NewReader(`{"redirects": [
+		{"from": "/news/:year/:slug", "to": "/blog/:year/:slug", "status": 308, "conditions": {"cookie": ["beta"]}},
+		{"from": "relative", "to": "/new"}
+	]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []redirectRule{{
+		from:    []string{"news", ":year", ":slug"},
+		to:      "/blog/:year/:slug",
+		status:  http.StatusPermanentRedirect,
+		cookies: []string{"beta"},
+	}}, rules)
+}
+
+func TestRedirectRule_Match(t *testing.T) {
+	testCases := []struct {
+		name       string
+		from       string
+		to         string
+		path       string
+		wantOK     bool
+		wantTarget string
+	}{{
+		name:       "Exact",
+		from:       "/old",
+		to:         "/new",
+		path:       "/old/",
+		wantOK:     true,
+		wantTarget: "/new",
+	}, {
+		name: "Mismatch",
+		from: "/old",
+		to:   "/new",
+		path: "/old/page",
+	}, {
+		name:       "Splat",
+		from:       "/news/*",
+		to:         "/blog/:splat",
+		path:       "/news/2023/hello world",
+		wantOK:     true,
+		wantTarget: "/blog/2023/hello%20world",
+	}, {
+		name:       "Placeholders",
+		from:       "/news/:year/:slug",
+		to:         "/blog/:year/:slug/",
+		path:       "/news/2023/hello",
+		wantOK:     true,
+		wantTarget: "/blog/2023/hello/",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := newRedirectRule(tc.from, tc.to, 0)
+			assert.NoError(t, err)
+
+			values, ok := rule.match(tc.path)
+
+			assert.Equal(t, tc.wantOK, ok)
+			if ok {
+				assert.Equal(t, tc.wantTarget, rule.target(values, true))
+			}
+		})
+	}
+}
+
+func TestRedirectRule_MatchConditions(t *testing.T) {
+	assert.NoError(t, config.LoadConf())
+
+	rule := redirectRule{
+		countries: []string{"jp"},
+		languages: []string{"ja"},
+		cookies:   []string{"beta"},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Client-Region", "JP")
+	req.Header.Set("Accept-Language", "en;q=0.8, ja-JP")
+	req.AddCookie(&http.Cookie{Name: "beta", Value: "1"})
+	assert.True(t, rule.matchConditions(req))
+
+	req.Header.Set("Accept-Language", "en, ja;q=0")
+	assert.False(t, rule.matchConditions(req))
+}
+
+func TestServeRedirectRules(t *testing.T) {
+	t.Setenv("REDIRECTS_FILE", "_redirects")
+	assert.NoError(t, config.LoadConf())
+
+	s := &site{bucket: (&storage.Client{}).Bucket("test"), prefix: "docs/"}
+	rules, err := parseRedirectRules(strings.NewReader(`
+/old /new
+/go/* /:splat
+/external/* https://example.com/:splat 302
+`))
+	assert.NoError(t, err)
+	redirectRules.entries["test/docs/_redirects"] = &generationEntry{value: rules, checked: time.Now()}
+	defer delete(redirectRules.entries, "test/docs/_redirects")
+
+	testCases := []struct {
+		name         string
+		url          string
+		path         string
+		wantOK       bool
+		wantStatus   int
+		wantLocation string
+	}{{
+		name:         "Under the mount",
+		url:          "/docs/old?lang=ja",
+		path:         "/old",
+		wantOK:       true,
+		wantStatus:   http.StatusMovedPermanently,
+		wantLocation: "/docs/new?lang=ja",
+	}, {
+		name:         "Protocol-relative splat",
+		url:          "/go//evil.example",
+		path:         "/go//evil.example",
+		wantOK:       true,
+		wantStatus:   http.StatusMovedPermanently,
+		wantLocation: "/evil.example",
+	}, {
+		name:         "External",
+		url:          "/external/a/b",
+		path:         "/external/a/b",
+		wantOK:       true,
+		wantStatus:   http.StatusFound,
+		wantLocation: "https://example.com/a/b",
+	}, {
+		name: "No match",
+		url:  "/docs/page",
+		path: "/page",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+
+			ok := serveRedirectRules(w, req, s, tc.path)
+
+			assert.Equal(t, tc.wantOK, ok)
+			if ok {
+				assert.Equal(t, tc.wantStatus, w.Code)
+				assert.Equal(t, tc.wantLocation, w.Header().Get("Location"))
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectRedirect(t *testing.T) {
+	testCases := []struct {
+		name         string
+		url          string
+		metadata     map[string]string
+		wantLocation string
+		wantStatus   int
+		wantOK       bool
+	}{{
+		name: "No redirect",
+		url:  "/docs/old.html",
+	}, {
+		name:         "Absolute path",
+		url:          "/docs/old.html",
+		metadata:     map[string]string{"gcsproxy-redirect-location": "/docs/new.html"},
+		wantLocation: "/docs/new.html",
+		wantStatus:   http.StatusMovedPermanently,
+		wantOK:       true,
+	}, {
+		name:         "Relative path",
+		url:          "/docs/v1/old.html",
+		metadata:     map[string]string{"gcsproxy-redirect-location": "../v2/new.html?lang=ja#intro"},
+		wantLocation: "/docs/v2/new.html?lang=ja#intro",
+		wantStatus:   http.StatusMovedPermanently,
+		wantOK:       true,
+	}, {
+		name: "Absolute URL with status",
+		url:  "/docs/old.html",
+		metadata: map[string]string{
+			"Gcsproxy-Redirect-Location": "https://docs.example.com/new.html",
+			"gcsproxy-redirect-status":   "307",
+		},
+		wantLocation: "https://docs.example.com/new.html",
+		wantStatus:   http.StatusTemporaryRedirect,
+		wantOK:       true,
+	}, {
+		name: "Invalid status",
+		url:  "/docs/old.html",
+		metadata: map[string]string{
+			"gcsproxy-redirect-location": "/docs/new.html",
+			"gcsproxy-redirect-status":   "200",
+		},
+	}, {
+		name:     "Protocol-relative URL",
+		url:      "/docs/old.html",
+		metadata: map[string]string{"gcsproxy-redirect-location": "//evil.example/"},
+	}, {
+		name:     "Backslash",
+		url:      "/docs/old.html",
+		metadata: map[string]string{"gcsproxy-redirect-location": "/\\evil.example/"},
+	}, {
+		name:     "Dot segments to protocol-relative URL",
+		url:      "/old.html",
+		metadata: map[string]string{"gcsproxy-redirect-location": "/.//evil.example/"},
+	}, {
+		name:     "JavaScript URL",
+		url:      "/docs/old.html",
+		metadata: map[string]string{"gcsproxy-redirect-location": "javascript:alert(1)"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			attrs := &storage.ObjectAttrs{Name: "object", Metadata: tc.metadata}
+
+			location, status, ok := objectRedirect(req, attrs)
+
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.wantLocation, location)
+			assert.Equal(t, tc.wantStatus, status)
+		})
+	}
+}
+
+func TestLocalLocation(t *testing.T) {
+	testCases := []struct {
+		name     string
+		location string
+		want     string
+	}{{
+		name:     "Path",
+		location: "/docs/",
+		want:     "/docs/",
+	}, {
+		name:     "Protocol-relative",
+		location: "//example.com/",
+		want:     "/example.com/",
+	}, {
+		name:     "Backslash",
+		location: "/\\\\example.com/",
+		want:     "/example.com/",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := localLocation(tc.location)
+
+			assert.Equal(t, tc.want, got)
+			assert.False(t, isProtocolRelative(got))
+		})
+	}
+}
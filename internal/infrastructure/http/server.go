@@ -1,13 +1,17 @@
 package http
 
 import (
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net"
 	"net/http"
+	"path"
+	"strconv"
 	"strings"
 
 	"cloud.google.com/go/storage"
@@ -17,10 +21,14 @@ import (
 	"github.com/aplulu/gcsproxy/internal/domain/model"
 	"github.com/aplulu/gcsproxy/internal/infrastructure/http/middleware"
 	appHttp "github.com/aplulu/gcsproxy/internal/interface/http"
+	"github.com/aplulu/gcsproxy/internal/interface/http/errorpage"
+	"github.com/aplulu/gcsproxy/internal/util"
 )
 
 const (
 	gcsProxyPathPrefix = "/_gcsproxy"
+	oidcPathPrefix     = gcsProxyPathPrefix + "/oidc"
+	apiPathPrefix      = gcsProxyPathPrefix + "/api/v1"
 )
 
 var server http.Server
@@ -31,10 +39,52 @@ func RunServer() error {
 	if err != nil {
 		return fmt.Errorf("http.RunServer: failed to create storage client: %w", err)
 	}
-	storageBucket := storageClient.Bucket(config.GoogleCloudStorageBucket())
+	sites := newSiteResolver(storageClient)
+	cachedObjects, err = newObjectCache()
+	if err != nil {
+		return fmt.Errorf("http.RunServer: failed to create object cache: %w", err)
+	}
+	siblings = newSiblingCache()
 
 	httpMux := chi.NewRouter()
 
+	// Request IDs are shown on the error pages
+	httpMux.Use(middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		Header: errorpage.RequestIDHeader,
+	}))
+	errorPageConf := errorpage.Config{
+		Loader:           errorPageLoader(sites),
+		JSONPathPrefixes: []string{apiPathPrefix},
+	}
+	if config.AuthType() == "oidc" {
+		errorPageConf.LoginURL = loginURL
+	}
+	errorpage.Configure(errorPageConf)
+
+	// Header rules apply to all responses including errors and authentication
+	headerRules, err := newHeaderRules(config.HeaderRules())
+	if err != nil {
+		return fmt.Errorf("http.RunServer: invalid header rules: %w", err)
+	}
+	httpMux.Use(middleware.HeaderRulesWithConfig(middleware.HeaderRulesConfig{
+		Rules: headerRules,
+	}))
+
+	// CORS preflights are answered before authentication
+	if len(config.CORSAllowedOrigins()) > 0 {
+		httpMux.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+			AllowOrigins:     config.CORSAllowedOrigins(),
+			AllowMethods:     config.CORSAllowedMethods(),
+			AllowHeaders:     config.CORSAllowedHeaders(),
+			ExposeHeaders:    config.CORSExposedHeaders(),
+			AllowCredentials: config.CORSAllowCredentials(),
+			MaxAge:           config.CORSMaxAge(),
+			Skipper: func(r *http.Request) bool {
+				return len(config.CORSPathPrefixes()) > 0 && !hasAnyPrefix(r.URL.Path, config.CORSPathPrefixes())
+			},
+		}))
+	}
+
 	// OpenID Connect
 	if config.AuthType() == "oidc" {
 		if err := config.ValidateOIDC(); err != nil {
@@ -46,15 +96,16 @@ func RunServer() error {
 			Issuer:      config.BaseURL(),
 			Audience:    config.BaseURL(),
 			SecretKey:   config.JWTSecret(),
-			RedirectURL: config.BaseURL() + gcsProxyPathPrefix + "/oidc/login",
+			RedirectURL: config.BaseURL() + oidcPathPrefix + "/login",
+			// preflights carry no cookies and must not be redirected to the login page
 			Skipper: func(r *http.Request) bool {
-				return strings.HasPrefix(r.URL.Path, gcsProxyPathPrefix)
+				return strings.HasPrefix(r.URL.Path, oidcPathPrefix) || middleware.IsPreflight(r)
 			},
 		}))
 
 		authMux := chi.NewRouter()
 		appHttp.Register(authMux)
-		httpMux.Mount(gcsProxyPathPrefix+"/oidc", authMux)
+		httpMux.Mount(oidcPathPrefix, authMux)
 	} else if config.AuthType() == "basic" { // Basic Auth
 		if err := config.ValidateBasicAuth(); err != nil {
 			return fmt.Errorf("http.StartServer: invalid Basic Auth config: %w", err)
@@ -63,29 +114,76 @@ func RunServer() error {
 		httpMux.Use(middleware.AuthBasicWithConfig(middleware.AuthBasicConfig{
 			User:     config.BasicAuthUser(),
 			Password: config.BasicAuthPassword(),
-			Skipper: func(r *http.Request) bool {
-				return strings.HasPrefix(r.URL.Path, gcsProxyPathPrefix)
-			},
+			Skipper:  middleware.IsPreflight,
 		}))
 	}
 
-	httpMux.Get("/*", func(w http.ResponseWriter, req *http.Request) {
-		if strings.HasPrefix(req.URL.Path, gcsProxyPathPrefix) {
-			http.NotFound(w, req)
-			return
-		}
+	if err := config.ValidateWrite(); err != nil {
+		return fmt.Errorf("http.RunServer: invalid write config: %w", err)
+	}
+
+	if err := config.ValidateDirectoryListing(); err != nil {
+		return fmt.Errorf("http.RunServer: invalid directory listing config: %w", err)
+	}
+
+	if err := config.ValidateCORS(); err != nil {
+		return fmt.Errorf("http.RunServer: invalid CORS config: %w", err)
+	}
 
-		ctx := req.Context()
+	// JSON API (protected by the same authentication as file serving)
+	if config.JSONAPI() {
+		apiMux := chi.NewRouter()
+		appHttp.RegisterAPI(apiMux, func(r *http.Request, prefix string) (*appHttp.ListScope, bool) {
+			s, ok := sites.resolveHost(r)
+			if !ok {
+				return nil, false
+			}
+			return listScope(s, prefix), true
+		})
+		httpMux.Mount(apiPathPrefix, apiMux)
+	}
+
+	if cachedObjects != nil {
+		httpMux.Get(gcsProxyPathPrefix+"/metrics", serveObjectCacheStats)
+	}
 
-		key := req.URL.Path
-		if len(config.MainPageSuffix()) > 0 && strings.HasSuffix(key, "/") {
-			key += config.MainPageSuffix()
+	fileHandler := siteHandler(sites, func(w http.ResponseWriter, req *http.Request, s *site, p string) {
+		if serveRedirectRules(w, req, s, p) {
+			return
 		}
 
-		key = strings.TrimPrefix(key, "/")
+		// the root of the mount is always a directory
+		if len(p) == 0 {
+			redirectToDirectory(w, req)
+			return
+		}
 
-		serveFile(ctx, storageBucket, key, w)
+		serveFile(w, req, s, s.mainPageKey(p))
 	})
+	httpMux.Get("/*", fileHandler)
+	httpMux.Head("/*", fileHandler)
+
+	// Uploads
+	if len(config.WritePathPrefixes()) > 0 {
+		writeHandler := siteHandler(sites, func(w http.ResponseWriter, req *http.Request, s *site, p string) {
+			key := s.objectKey(p)
+
+			if req.Method == http.MethodPost {
+				serveFormUpload(w, req, s.bucket, key)
+			} else {
+				serveUpload(w, req, s.bucket, key)
+			}
+		})
+		httpMux.Put("/*", writeHandler)
+		httpMux.Post("/*", writeHandler)
+	}
+
+	// Deletions
+	if len(config.DeletePathPrefixes()) > 0 {
+		httpMux.Delete("/*", siteHandler(sites, func(w http.ResponseWriter, req *http.Request, s *site, p string) {
+			serveDelete(w, req, s.bucket, s.objectKey(p))
+		}))
+	}
 
 	server = http.Server{
 		Addr:    net.JoinHostPort(config.Listen(), config.Port()),
@@ -99,58 +197,417 @@ func RunServer() error {
 	return nil
 }
 
-func serveFile(ctx context.Context, storageBucket *storage.BucketHandle, key string, w http.ResponseWriter) {
-	obj := storageBucket.Object(key)
-	attrs, err := obj.Attrs(ctx)
+// newHeaderRules creates the header rules of the middleware from the configuration.
+func newHeaderRules(rules config.HeaderRuleList) ([]middleware.HeaderRule, error) {
+	var headerRules []middleware.HeaderRule
+	for _, rule := range rules {
+		re, err := rule.PathRegexp()
+		if err != nil {
+			return nil, fmt.Errorf("http.newHeaderRules: invalid path: %w", err)
+		}
+		headerRules = append(headerRules, middleware.HeaderRule{
+			Path:        re,
+			ContentType: rule.ContentType,
+			Set:         rule.Set,
+			Append:      rule.Append,
+			Remove:      rule.Remove,
+		})
+	}
+	return headerRules, nil
+}
+
+// siteHandler returns the handler that serves the request with the site resolved from the request host and path.
+func siteHandler(sites *siteResolver, h func(w http.ResponseWriter, req *http.Request, s *site, p string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, gcsProxyPathPrefix) {
+			errorpage.Write(w, req, http.StatusNotFound, "not found")
+			return
+		}
+
+		s, p, ok := sites.resolve(req)
+		if !ok {
+			responseError(w, req, storage.ErrBucketNotExist)
+			return
+		}
+
+		h(w, req, s, p)
+	}
+}
+
+func serveFile(w http.ResponseWriter, req *http.Request, s *site, key string) {
+	serveObject(w, req, s, key, http.StatusOK)
+}
+
+// serveObject serves the object with the status code.
+// Conditional and Range requests are handled only for 200 OK.
+func serveObject(w http.ResponseWriter, req *http.Request, s *site, key string, status int) {
+	serveObjectAttempt(w, req, s, key, status, true)
+}
+
+// serveObjectAttempt serves the object with the status code.
+// If retry is true and the generation of the attributes no longer exists, which happens when the cached attributes are outdated,
+// the object is served once more with fresh attributes. Errors of the contents are returned before the response headers are written.
+func serveObjectAttempt(w http.ResponseWriter, req *http.Request, s *site, key string, status int, retry bool) {
+	ctx := req.Context()
+
+	obj := s.bucket.Object(key)
+	var attrs *storage.ObjectAttrs
+	var prefetched *storage.Reader
+	var err error
+	if canPrefetch(req) {
+		attrs, prefetched, err = prefetchObject(ctx, obj)
+	} else {
+		attrs, err = cachedObjects.attrs(ctx, obj)
+	}
 	if err != nil {
-		// fallback to Not Found Page
-		if errors.Is(err, storage.ErrObjectNotExist) && len(config.NotFoundPage()) > 0 && key != config.NotFoundPage() {
-			serveFile(ctx, storageBucket, config.NotFoundPage(), w)
+		if errors.Is(err, storage.ErrObjectNotExist) && status == http.StatusOK {
+			serveNotFound(w, req, s, key)
 			return
 		}
-		responseError(w, err)
+		responseError(w, req, err)
 		return
 	}
+	defer func() {
+		if prefetched != nil {
+			prefetched.Close()
+		}
+	}()
 
-	r, err := obj.NewReader(ctx)
-	if err != nil {
-		responseError(w, err)
+	// redirect placeholder objects answer with the location in their metadata instead of the contents
+	if status == http.StatusOK {
+		if location, code, ok := objectRedirect(req, attrs); ok {
+			writeCacheControlHeader(w, attrs)
+			http.Redirect(w, req, location, code)
+			return
+		}
+	}
+
+	// infer the content type of objects uploaded without one before it is used to negotiate compression
+	attrs = inferContentType(ctx, obj, key, attrs)
+
+	// read the generation of the attributes so that the headers and the contents always match
+	obj = obj.Generation(attrs.Generation)
+
+	// serve the pre-compressed sibling object if the client accepts it
+	if len(config.PrecompressedEncodings()) > 0 && len(attrs.ContentEncoding) == 0 {
+		addVary(w, "Accept-Encoding")
+		if siblingObj, siblingAttrs, ok := findPrecompressed(req, s, key, attrs); ok {
+			obj, attrs = siblingObj, siblingAttrs
+			if prefetched != nil {
+				prefetched.Close()
+				prefetched = nil
+			}
+		}
+	}
+
+	// compress the object on the fly if the client accepts it
+	etag := objectETag(attrs)
+	var encoding string
+	if isCompressible(attrs) {
+		addVary(w, "Accept-Encoding")
+		if encoding = negotiateCompression(req, attrs); len(encoding) > 0 {
+			etag = representationETag(etag, encoding)
+		}
+	}
+
+	// serve gzip-encoded objects as stored, decompressing them for clients not accepting gzip
+	var decompress bool
+	if isGzipEncoded(attrs) {
+		addVary(w, "Accept-Encoding")
+		obj = obj.ReadCompressed(true)
+		if decompress = !util.AcceptsEncoding(req, "gzip"); decompress {
+			etag = representationETag(etag, "identity")
+		}
+	}
+
+	if status == http.StatusOK {
+		if err := checkPreconditions(req, attrs, etag); err != nil {
+			if errors.Is(err, model.ErrNotModified) {
+				writeNotModified(w, attrs, etag)
+				return
+			}
+			responseError(w, req, err)
+			return
+		}
+
+		w.Header().Set("Accept-Ranges", "bytes")
+	}
+
+	// HEAD requests are served only from the object attributes
+	if req.Method == http.MethodHead {
+		writeHeaders(w, attrs, false)
+		if len(encoding) > 0 {
+			writeCompressionHeaders(w, encoding, etag)
+		} else if decompress {
+			writeDecompressionHeaders(w, etag)
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	if rangeHeader := req.Header.Get("Range"); len(rangeHeader) > 0 && len(encoding) == 0 && !decompress && status == http.StatusOK && checkIfRange(req, attrs) {
+		ranges, err := parseRange(rangeHeader, attrs.Size)
+		if err != nil && !errors.Is(err, model.ErrInvalidRange) {
+			if errors.Is(err, model.ErrRangeNotSatisfiable) {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", attrs.Size))
+			}
+			responseError(w, req, err)
+			return
+		}
+		if len(ranges) > 0 {
+			if err := serveRanges(w, req, obj, attrs, ranges); err != nil {
+				if retry && errors.Is(err, storage.ErrObjectNotExist) {
+					cachedObjects.invalidate(obj)
+					siblings.invalidate(obj)
+					serveObjectAttempt(w, req, s, key, status, false)
+					return
+				}
+				responseError(w, req, err)
+			}
+			return
+		}
+	}
+
+	var r io.ReadCloser
+	if prefetched != nil {
+		r, prefetched = prefetched, nil
+	} else if r, err = cachedObjects.newReader(ctx, obj, attrs); err != nil {
+		if retry && errors.Is(err, storage.ErrObjectNotExist) {
+			cachedObjects.invalidate(obj)
+			siblings.invalidate(obj)
+			serveObjectAttempt(w, req, s, key, status, false)
+			return
+		}
+		responseError(w, req, err)
+		return
+	}
+	defer r.Close()
+
+	if len(encoding) > 0 {
+		writeHeaders(w, attrs, true)
+		writeCompressionHeaders(w, encoding, etag)
+		w.WriteHeader(status)
+
+		cw := newCompressResponseWriter(w, encoding)
+		copyContent(cw, r, attrs.Size > 32*1024*1024)
+		if err := cw.Close(); err != nil {
+			log.Printf("http.serveObject: failed to close compress writer: %v\n", err)
+		}
+		return
+	}
+
+	if decompress {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			responseError(w, req, err)
+			return
+		}
+		defer gr.Close()
+
+		writeHeaders(w, attrs, true)
+		writeDecompressionHeaders(w, etag)
+		w.WriteHeader(status)
+
+		copyContent(w, gr, attrs.Size > 32*1024*1024)
 		return
 	}
 
 	// if file size is larger than 32MB, use chunked transfer encoding
 	if attrs.Size > 32*1024*1024 {
-		flusher, ok := w.(http.Flusher)
-		if !ok {
-			responseError(w, model.ErrStreamingUnsupported)
+		if _, ok := w.(http.Flusher); !ok {
+			responseError(w, req, model.ErrStreamingUnsupported)
 			return
 		}
 
 		// write headers
 		writeHeaders(w, attrs, true)
+		w.WriteHeader(status)
 
-		buf := make([]byte, 32*1024)
-		for {
-			n, err := r.Read(buf)
-			if err != nil {
-				if err == io.EOF || err == context.Canceled {
-					break
-				}
-				log.Printf("http.RunServer: failed to read content: %v\n", err)
-				break
-			}
-			if _, err := w.Write(buf[:n]); err != nil {
-				log.Printf("http.RunServer: failed to write content: %v\n", err)
-				break
-			}
-			flusher.Flush()
-		}
+		copyContent(w, r, true)
 	} else {
 		// write headers
 		writeHeaders(w, attrs, false)
+		w.WriteHeader(status)
+
+		copyContent(w, r, false)
+	}
+}
+
+// canPrefetch reports whether the contents of the object are read along with its attributes.
+// HEAD, Range and conditional requests need only the attributes first, and the object cache serves the attributes without a round trip.
+// Clients accepting pre-compressed encodings may be served a sibling object instead, whose contents would be opened in vain.
+func canPrefetch(req *http.Request) bool {
+	if req.Method != http.MethodGet || cachedObjects != nil {
+		return false
+	}
+	for _, name := range []string{"Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		if len(req.Header.Get(name)) > 0 {
+			return false
+		}
+	}
+	for _, encoding := range config.PrecompressedEncodings() {
+		if util.AcceptsEncoding(req, encoding) {
+			return false
+		}
+	}
+	return true
+}
+
+// prefetchObject opens the reader of the object concurrently with the attribute lookup, so that serving the object waits for one round trip instead of two.
+// The reader is discarded if the object is replaced in the meantime, so that the contents are read from the generation of the attributes.
+func prefetchObject(ctx context.Context, obj *storage.ObjectHandle) (*storage.ObjectAttrs, *storage.Reader, error) {
+	type result struct {
+		r   *storage.Reader
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		// serve gzip-encoded objects as stored
+		r, err := obj.ReadCompressed(true).NewReader(ctx)
+		ch <- result{r: r, err: err}
+	}()
+
+	attrs, err := lookupAttrs(ctx, obj)
+	res := <-ch
+	if err != nil || res.err != nil || res.r.Attrs.Generation != attrs.Generation {
+		if res.r != nil {
+			res.r.Close()
+		}
+		return attrs, nil, err
+	}
+
+	return attrs, res.r, nil
+}
+
+// serveNotFound handles the request for the missing object.
+func serveNotFound(w http.ResponseWriter, req *http.Request, s *site, key string) {
+	// render the directory listing if the main page of the prefix is missing
+	if prefix, ok := listingPrefix(req, s, key); ok && config.DirectoryListing() {
+		serveDirectoryListing(w, req, s, prefix)
+		return
+	}
+
+	// redirect to the directory if its main page exists, like GCS static website hosting
+	if !strings.HasSuffix(req.URL.Path, "/") && len(s.mainPageSuffix) > 0 {
+		if _, err := cachedObjects.attrs(req.Context(), s.bucket.Object(key+"/"+s.mainPageSuffix)); err == nil {
+			redirectToDirectory(w, req)
+			return
+		}
+	}
+
+	// fallback to the entry document of the single-page app for extensionless paths
+	if spaKey := s.spaEntryKey(); len(spaKey) > 0 && key != spaKey && path.Ext(req.URL.Path) == "" {
+		serveObject(w, req, s, spaKey, http.StatusOK)
+		return
+	}
+
+	serveNotFoundPage(w, req, s, key)
+}
+
+// redirectToDirectory redirects to the request path with a trailing slash, keeping the query string.
+func redirectToDirectory(w http.ResponseWriter, req *http.Request) {
+	location := localLocation(req.URL.EscapedPath() + "/")
+	if len(req.URL.RawQuery) > 0 {
+		location += "?" + req.URL.RawQuery
+	}
+
+	http.Redirect(w, req, location, http.StatusMovedPermanently)
+}
+
+// serveNotFoundPage serves the Not Found Page with 404 Not Found if configured.
+func serveNotFoundPage(w http.ResponseWriter, req *http.Request, s *site, key string) {
+	// fallback to Not Found Page
+	if notFoundKey := s.notFoundKey(); len(notFoundKey) > 0 && key != notFoundKey {
+		serveObject(w, req, s, notFoundKey, http.StatusNotFound)
+		return
+	}
+
+	responseError(w, req, storage.ErrObjectNotExist)
+}
+
+// serveRanges serves the requested byte ranges of the object with 206 Partial Content.
+// It returns the error if the first range cannot be opened, before the response headers are written.
+func serveRanges(w http.ResponseWriter, req *http.Request, obj *storage.ObjectHandle, attrs *storage.ObjectAttrs, ranges []httpRange) error {
+	ctx := req.Context()
+
+	r, err := cachedObjects.newRangeReader(ctx, obj, attrs, ranges[0].start, ranges[0].length)
+	if err != nil {
+		return err
+	}
+
+	if len(ranges) == 1 {
+		defer r.Close()
+
+		ra := ranges[0]
+		writeHeaders(w, attrs, false)
+		w.Header().Set("Content-Range", ra.contentRange(attrs.Size))
+		w.Header().Set("Content-Length", strconv.FormatInt(ra.length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+
+		copyContent(w, r, ra.length > 32*1024*1024)
+		return nil
+	}
+
+	mw := multipart.NewWriter(w)
+
+	writeHeaders(w, attrs, true)
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusPartialContent)
+
+	for i, ra := range ranges {
+		if i > 0 {
+			if r, err = cachedObjects.newRangeReader(ctx, obj, attrs, ra.start, ra.length); err != nil {
+				log.Printf("http.serveRanges: failed to open range reader: %v\n", err)
+				return nil
+			}
+		}
+
+		part, err := mw.CreatePart(ra.mimeHeader(attrs.ContentType, attrs.Size))
+		if err != nil {
+			r.Close()
+			log.Printf("http.serveRanges: failed to create part: %v\n", err)
+			return nil
+		}
+		_, err = io.Copy(part, r)
+		r.Close()
+		if err != nil {
+			log.Printf("http.serveRanges: failed to copy content: %v\n", err)
+			return nil
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		log.Printf("http.serveRanges: failed to close multipart writer: %v\n", err)
+	}
+	return nil
+}
 
+// copyContent copies the object content to the response. If flush is true, the response is flushed after each chunk.
+func copyContent(w http.ResponseWriter, r io.Reader, flush bool) {
+	flusher, ok := w.(http.Flusher)
+	if !flush || !ok {
 		if _, err := io.Copy(w, r); err != nil {
-			log.Printf("http.RunServer: failed to copy content: %v\n", err)
+			log.Printf("http.copyContent: failed to copy content: %v\n", err)
+		}
+		return
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				log.Printf("http.copyContent: failed to write content: %v\n", err)
+				return
+			}
+			flusher.Flush()
+		}
+		if err != nil {
+			if err == io.EOF || errors.Is(err, context.Canceled) {
+				return
+			}
+			log.Printf("http.copyContent: failed to read content: %v\n", err)
+			return
 		}
 	}
 }
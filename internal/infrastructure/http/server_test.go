@@ -0,0 +1,223 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+)
+
+func TestRedirectToDirectory(t *testing.T) {
+	testCases := []struct {
+		name string
+		url  string
+		want string
+	}{{
+		name: "Path",
+		url:  "/docs",
+		want: "/docs/",
+	}, {
+		name: "Path with query",
+		url:  "/docs?lang=ja",
+		want: "/docs/?lang=ja",
+	}, {
+		name: "Escaped path",
+		url:  "/my%20docs",
+		want: "/my%20docs/",
+	}, {
+		name: "Protocol-relative path",
+		url:  "//evil.example",
+		want: "/evil.example/",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+
+			redirectToDirectory(w, req)
+
+			assert.Equal(t, http.StatusMovedPermanently, w.Code)
+			assert.Equal(t, tc.want, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestCanPrefetch(t *testing.T) {
+	testCases := []struct {
+		name   string
+		method string
+		header http.Header
+		want   bool
+	}{{
+		name:   "GET",
+		method: http.MethodGet,
+		header: http.Header{},
+		want:   true,
+	}, {
+		name:   "HEAD",
+		method: http.MethodHead,
+		header: http.Header{},
+	}, {
+		name:   "Range",
+		method: http.MethodGet,
+		header: http.Header{"Range": {"bytes=0-99"}},
+	}, {
+		name:   "Conditional",
+		method: http.MethodGet,
+		header: http.Header{"If-None-Match": {`"abc-1"`}},
+	}, {
+		name:   "Accepts pre-compressed encoding",
+		method: http.MethodGet,
+		header: http.Header{"Accept-Encoding": {"gzip, br"}},
+	}, {
+		name:   "Does not accept pre-compressed encoding",
+		method: http.MethodGet,
+		header: http.Header{"Accept-Encoding": {"zstd"}},
+		want:   true,
+	}}
+
+	t.Setenv("PRECOMPRESSED_ENCODINGS", "br")
+	assert.NoError(t, config.LoadConf())
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &http.Request{Method: tc.method, Header: tc.header}
+
+			assert.Equal(t, tc.want, canPrefetch(req))
+		})
+	}
+}
+
+func TestPrefetchObject(t *testing.T) {
+	assert.NoError(t, config.LoadConf())
+
+	t.Run("Same generation", func(t *testing.T) {
+		gcs, bucket := newFakeGCS(t)
+		generation := gcs.put("a.txt", "contents", "text/plain")
+
+		attrs, r, err := prefetchObject(context.Background(), bucket.Object("a.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, generation, attrs.Generation)
+		assert.NotNil(t, r)
+		r.Close()
+	})
+
+	t.Run("Generation mismatch", func(t *testing.T) {
+		gcs, bucket := newFakeGCS(t)
+		gcs.put("a.txt", "old", "text/plain")
+		gcs.put("a.txt", "new", "text/plain")
+		// the reader opens the replaced generation while the attributes refer to the new one
+		gcs.mu.Lock()
+		gcs.staleReads = true
+		gcs.mu.Unlock()
+
+		attrs, r, err := prefetchObject(context.Background(), bucket.Object("a.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), attrs.Generation)
+		assert.Nil(t, r)
+
+		// the contents are read again from the generation of the attributes
+		w := httptest.NewRecorder()
+		serveObject(w, httptest.NewRequest(http.MethodGet, "/a.txt", nil), &site{bucket: bucket}, "a.txt", http.StatusOK)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "new", w.Body.String())
+	})
+
+	t.Run("Missing object", func(t *testing.T) {
+		_, bucket := newFakeGCS(t)
+
+		_, r, err := prefetchObject(context.Background(), bucket.Object("a.txt"))
+		assert.ErrorIs(t, err, storage.ErrObjectNotExist)
+		assert.Nil(t, r)
+	})
+}
+
+func TestServeObject_Head(t *testing.T) {
+	assert.NoError(t, config.LoadConf())
+
+	gcs, bucket := newFakeGCS(t)
+	gcs.put("a.txt", "contents", "text/plain")
+	s := &site{bucket: bucket}
+
+	head := httptest.NewRecorder()
+	serveObject(head, httptest.NewRequest(http.MethodHead, "/a.txt", nil), s, "a.txt", http.StatusOK)
+
+	assert.Equal(t, http.StatusOK, head.Code)
+	assert.Empty(t, head.Body.String())
+	assert.Equal(t, 0, gcs.readsCount())
+
+	get := httptest.NewRecorder()
+	serveObject(get, httptest.NewRequest(http.MethodGet, "/a.txt", nil), s, "a.txt", http.StatusOK)
+
+	assert.Equal(t, "contents", get.Body.String())
+	for _, name := range []string{"Content-Length", "ETag", "Content-Type"} {
+		assert.NotEmpty(t, get.Header().Get(name))
+		assert.Equal(t, get.Header().Get(name), head.Header().Get(name), name)
+	}
+}
+
+func TestServeNotFound(t *testing.T) {
+	assert.NoError(t, config.LoadConf())
+
+	testCases := []struct {
+		name        string
+		site        site
+		path        string
+		wantStatus  int
+		wantBody    string
+		wantDefault bool
+	}{{
+		name:       "Not Found Page",
+		site:       site{notFoundPage: "404.html"},
+		path:       "/missing.html",
+		wantStatus: http.StatusNotFound,
+		wantBody:   "not found page",
+	}, {
+		name:       "SPA fallback",
+		site:       site{notFoundPage: "404.html", spaEntry: "index.html"},
+		path:       "/route/x",
+		wantStatus: http.StatusOK,
+		wantBody:   "entry document",
+	}, {
+		name:       "SPA fallback with extension",
+		site:       site{notFoundPage: "404.html", spaEntry: "index.html"},
+		path:       "/missing.js",
+		wantStatus: http.StatusNotFound,
+		wantBody:   "not found page",
+	}, {
+		name:        "Missing Not Found Page",
+		site:        site{notFoundPage: "missing-404.html"},
+		path:        "/missing.html",
+		wantStatus:  http.StatusNotFound,
+		wantDefault: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcs, bucket := newFakeGCS(t)
+			gcs.put("404.html", "not found page", "text/html")
+			gcs.put("index.html", "entry document", "text/html")
+			s := tc.site
+			s.bucket = bucket
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			serveObject(w, req, &s, s.objectKey(req.URL.Path), http.StatusOK)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.wantDefault {
+				assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+				assert.Contains(t, w.Body.String(), http.StatusText(http.StatusNotFound))
+			} else {
+				assert.Equal(t, tc.wantBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+	appHttp "github.com/aplulu/gcsproxy/internal/interface/http"
+)
+
+// site is the bucket and settings to serve objects for the request.
+type site struct {
+	bucket         *storage.BucketHandle
+	prefix         string
+	mainPageSuffix string
+	notFoundPage   string
+	spaEntry       string
+	mounts         []*mount
+}
+
+// mount maps the URL path prefix to the site.
+type mount struct {
+	pathPrefix string
+	site       *site
+}
+
+// newSite creates the site from the mount configuration, inheriting unset settings from the parent.
+func newSite(parent *site, bucket func(name string) *storage.BucketHandle, m config.Mount) *site {
+	s := &site{
+		bucket:         parent.bucket,
+		prefix:         m.Prefix,
+		mainPageSuffix: parent.mainPageSuffix,
+		notFoundPage:   parent.notFoundPage,
+		spaEntry:       parent.spaEntry,
+	}
+	if len(m.Bucket) > 0 {
+		s.bucket = bucket(m.Bucket)
+	}
+	if m.MainPageSuffix != nil {
+		s.mainPageSuffix = *m.MainPageSuffix
+	}
+	if m.NotFoundPage != nil {
+		s.notFoundPage = *m.NotFoundPage
+	}
+	if m.SPAEntryDocument != nil {
+		s.spaEntry = *m.SPAEntryDocument
+	}
+	return s
+}
+
+// addMounts adds the mounts to the site, sorted by the longest path prefix first.
+func (s *site) addMounts(bucket func(name string) *storage.BucketHandle, mounts config.MountMap) {
+	for p, m := range mounts {
+		s.mounts = append(s.mounts, &mount{
+			pathPrefix: p,
+			site:       newSite(s, bucket, m),
+		})
+	}
+	sort.Slice(s.mounts, func(i, j int) bool {
+		return len(s.mounts[i].pathPrefix) > len(s.mounts[j].pathPrefix)
+	})
+}
+
+// resolveMount returns the site of the longest matching mount and the path with the mount prefix stripped.
+// The path is empty if it equals the mount prefix.
+func (s *site) resolveMount(p string) (*site, string) {
+	for _, m := range s.mounts {
+		if m.pathPrefix == "/" {
+			return m.site, p
+		}
+		if p == m.pathPrefix {
+			return m.site, ""
+		}
+		if strings.HasPrefix(p, m.pathPrefix+"/") {
+			return m.site, p[len(m.pathPrefix):]
+		}
+	}
+	return s, p
+}
+
+// listScope returns the scope to list objects for the URL path prefix (without the leading "/"), resolved through the mounts.
+// The listing does not cross into other mounts, and the names are returned as URL paths.
+func listScope(s *site, prefix string) *appHttp.ListScope {
+	p := "/" + prefix
+	ms, rel := s.resolveMount(p)
+
+	pathPrefix := strings.TrimPrefix(p[:len(p)-len(rel)], "/")
+	if len(pathPrefix) > 0 {
+		pathPrefix += "/"
+	}
+	return &appHttp.ListScope{
+		Bucket:     ms.bucket,
+		Root:       ms.prefix,
+		PathPrefix: pathPrefix,
+		Prefix:     strings.TrimPrefix(rel, "/"),
+	}
+}
+
+// objectKey returns the object key for the request path.
+func (s *site) objectKey(p string) string {
+	return s.prefix + strings.TrimPrefix(p, "/")
+}
+
+// mainPageKey returns the object key for the request path, appending the main page suffix to directories.
+func (s *site) mainPageKey(p string) string {
+	if len(s.mainPageSuffix) > 0 && strings.HasSuffix(p, "/") {
+		p += s.mainPageSuffix
+	}
+	return s.objectKey(p)
+}
+
+// notFoundKey returns the object key of the Not Found Page, or an empty string if not configured.
+func (s *site) notFoundKey() string {
+	if len(s.notFoundPage) == 0 {
+		return ""
+	}
+	return s.prefix + s.notFoundPage
+}
+
+// spaEntryKey returns the object key of the single-page app entry document, or an empty string if not configured.
+func (s *site) spaEntryKey() string {
+	if len(s.spaEntry) == 0 {
+		return ""
+	}
+	return s.prefix + s.spaEntry
+}
+
+// siteResolver resolves the site for the request host.
+type siteResolver struct {
+	defaultSite *site
+	hosts       map[string]*site
+	wildcards   map[string]*site
+}
+
+// newSiteResolver creates siteResolver from the configuration.
+func newSiteResolver(storageClient *storage.Client) *siteResolver {
+	buckets := make(map[string]*storage.BucketHandle)
+	bucket := func(name string) *storage.BucketHandle {
+		if b, ok := buckets[name]; ok {
+			return b
+		}
+		b := storageClient.Bucket(name)
+		buckets[name] = b
+		return b
+	}
+
+	r := &siteResolver{
+		hosts:     make(map[string]*site),
+		wildcards: make(map[string]*site),
+	}
+
+	// the default bucket serves hosts without a virtual host entry
+	if len(config.GoogleCloudStorageBucket()) > 0 || len(config.VirtualHosts()) == 0 {
+		r.defaultSite = &site{
+			bucket:         bucket(config.GoogleCloudStorageBucket()),
+			mainPageSuffix: config.MainPageSuffix(),
+			notFoundPage:   config.NotFoundPage(),
+			spaEntry:       config.SPAEntryDocument(),
+		}
+		r.defaultSite.addMounts(bucket, config.Mounts())
+	}
+
+	root := &site{
+		mainPageSuffix: config.MainPageSuffix(),
+		notFoundPage:   config.NotFoundPage(),
+		spaEntry:       config.SPAEntryDocument(),
+	}
+	for host, vh := range config.VirtualHosts() {
+		s := newSite(root, bucket, vh.Mount)
+		s.addMounts(bucket, vh.Mounts)
+
+		if strings.HasPrefix(host, "*.") {
+			r.wildcards[host[1:]] = s
+		} else {
+			r.hosts[host] = s
+		}
+	}
+
+	return r
+}
+
+// resolve returns the site for the request and the request path relative to the site.
+// Exact host names take precedence over the most specific matching wildcard, then the longest matching mount is applied.
+func (r *siteResolver) resolve(req *http.Request) (*site, string, bool) {
+	s, ok := r.resolveHost(req)
+	if !ok {
+		return nil, "", false
+	}
+
+	s, p := s.resolveMount(req.URL.Path)
+	return s, p, true
+}
+
+// resolveHost returns the site for the request host.
+func (r *siteResolver) resolveHost(req *http.Request) (*site, bool) {
+	host := strings.ToLower(req.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if s, ok := r.hosts[host]; ok {
+		return s, true
+	}
+
+	for suffix := host; ; suffix = suffix[1:] {
+		i := strings.Index(suffix, ".")
+		if i < 0 {
+			break
+		}
+		suffix = suffix[i:]
+		if s, ok := r.wildcards[suffix]; ok {
+			return s, true
+		}
+	}
+
+	return r.defaultSite, r.defaultSite != nil
+}
@@ -0,0 +1,173 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSiteResolver_ResolveHost(t *testing.T) {
+	defaultSite := &site{prefix: "default/"}
+	docsSite := &site{prefix: "docs/"}
+	wildcardSite := &site{prefix: "wildcard/"}
+	nestedSite := &site{prefix: "nested/"}
+
+	r := &siteResolver{
+		defaultSite: defaultSite,
+		hosts: map[string]*site{
+			"docs.example.com": docsSite,
+		},
+		wildcards: map[string]*site{
+			".example.com":      wildcardSite,
+			".team.example.com": nestedSite,
+		},
+	}
+
+	testCases := []struct {
+		name string
+		host string
+		want *site
+	}{{
+		name: "Exact host",
+		host: "docs.example.com",
+		want: docsSite,
+	}, {
+		name: "Exact host with port",
+		host: "Docs.Example.com:8080",
+		want: docsSite,
+	}, {
+		name: "Wildcard host",
+		host: "reports.example.com",
+		want: wildcardSite,
+	}, {
+		name: "Most specific wildcard host",
+		host: "a.team.example.com",
+		want: nestedSite,
+	}, {
+		name: "Default host",
+		host: "localhost:8080",
+		want: defaultSite,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := r.resolveHost(&http.Request{Host: tc.host})
+
+			assert.True(t, ok)
+			assert.Same(t, tc.want, got)
+		})
+	}
+}
+
+func TestSite_ResolveMount(t *testing.T) {
+	s := &site{prefix: "root/"}
+	assets := &site{prefix: "v2/"}
+	images := &site{prefix: "images/"}
+	s.mounts = []*mount{
+		{pathPrefix: "/assets/images", site: images},
+		{pathPrefix: "/assets", site: assets},
+	}
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantSite *site
+		wantPath string
+	}{{
+		name:     "No mount",
+		path:     "/index.html",
+		wantSite: s,
+		wantPath: "/index.html",
+	}, {
+		name:     "Mount",
+		path:     "/assets/app.js",
+		wantSite: assets,
+		wantPath: "/app.js",
+	}, {
+		name:     "Longest mount",
+		path:     "/assets/images/logo.png",
+		wantSite: images,
+		wantPath: "/logo.png",
+	}, {
+		name:     "Mount root",
+		path:     "/assets",
+		wantSite: assets,
+		wantPath: "",
+	}, {
+		name:     "Partial segment",
+		path:     "/assetsx/app.js",
+		wantSite: s,
+		wantPath: "/assetsx/app.js",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotSite, gotPath := s.resolveMount(tc.path)
+
+			assert.Same(t, tc.wantSite, gotSite)
+			assert.Equal(t, tc.wantPath, gotPath)
+		})
+	}
+}
+
+func TestSite_MainPageKey(t *testing.T) {
+	s := &site{prefix: "public/", mainPageSuffix: "index.html"}
+
+	assert.Equal(t, "public/index.html", s.mainPageKey("/"))
+	assert.Equal(t, "public/docs/index.html", s.mainPageKey("/docs/"))
+	assert.Equal(t, "public/docs/a.html", s.mainPageKey("/docs/a.html"))
+}
+
+func TestListScope(t *testing.T) {
+	s := &site{prefix: "root/"}
+	assets := &site{prefix: "v2/"}
+	s.mounts = []*mount{
+		{pathPrefix: "/assets", site: assets},
+	}
+
+	testCases := []struct {
+		name           string
+		prefix         string
+		wantRoot       string
+		wantPathPrefix string
+		wantPrefix     string
+	}{{
+		name:       "Root",
+		prefix:     "",
+		wantRoot:   "root/",
+		wantPrefix: "",
+	}, {
+		name:       "No mount",
+		prefix:     "docs/",
+		wantRoot:   "root/",
+		wantPrefix: "docs/",
+	}, {
+		name:           "Mount",
+		prefix:         "assets/img/",
+		wantRoot:       "v2/",
+		wantPathPrefix: "assets/",
+		wantPrefix:     "img/",
+	}, {
+		name:           "Mount path",
+		prefix:         "assets",
+		wantRoot:       "v2/",
+		wantPathPrefix: "assets/",
+		wantPrefix:     "",
+	}, {
+		name:       "Not a mount segment",
+		prefix:     "assets-old/",
+		wantRoot:   "root/",
+		wantPrefix: "assets-old/",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := listScope(s, tc.prefix)
+
+			assert.Equal(t, tc.wantRoot, got.Root)
+			assert.Equal(t, tc.wantPathPrefix, got.PathPrefix)
+			assert.Equal(t, tc.wantPrefix, got.Prefix)
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+	"github.com/aplulu/gcsproxy/internal/domain/model"
+	"github.com/aplulu/gcsproxy/internal/interface/http/errorpage"
+	"github.com/aplulu/gcsproxy/internal/util"
+)
+
+const (
+	uploadFormFileField     = "file"
+	uploadFormRedirectField = "redirect"
+)
+
+// serveUpload streams the request body into the object.
+func serveUpload(w http.ResponseWriter, req *http.Request, storageBucket *storage.BucketHandle, key string) {
+	ctx := req.Context()
+
+	if !isWritable(req.URL.Path) {
+		responseError(w, req, model.ErrForbidden)
+		return
+	}
+	if !isValidObjectName(key) || strings.HasSuffix(key, "/") {
+		responseError(w, req, model.ErrInvalidObjectName)
+		return
+	}
+	if req.ContentLength > config.UploadMaxSize() {
+		responseError(w, req, model.ErrRequestTooLarge)
+		return
+	}
+
+	generationMatch, err := parseGenerationMatch(req)
+	if err != nil {
+		responseError(w, req, err)
+		return
+	}
+
+	contentType := req.Header.Get("Content-Type")
+	if len(contentType) == 0 {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+
+	body := http.MaxBytesReader(w, req.Body, config.UploadMaxSize())
+	obj, err := model.WriteObject(ctx, storageBucket, key, body, contentType, generationMatch)
+	if err != nil {
+		responseError(w, req, err)
+		return
+	}
+	cachedObjects.invalidate(storageBucket.Object(key))
+
+	responseJSON(w, http.StatusCreated, obj)
+}
+
+// serveFormUpload stores the files of the multipart form into the objects.
+// If the path ends with "/", the files are stored under the path with their file names. Otherwise, a single file is stored as the path.
+func serveFormUpload(w http.ResponseWriter, req *http.Request, storageBucket *storage.BucketHandle, key string) {
+	ctx := req.Context()
+
+	if !isSameOrigin(req) || !isWritable(req.URL.Path) {
+		responseError(w, req, model.ErrForbidden)
+		return
+	}
+	if !isValidObjectName(key) {
+		responseError(w, req, model.ErrInvalidObjectName)
+		return
+	}
+	if req.ContentLength > config.UploadMaxSize() {
+		responseError(w, req, model.ErrRequestTooLarge)
+		return
+	}
+
+	generationMatch, err := parseGenerationMatch(req)
+	if err != nil {
+		responseError(w, req, err)
+		return
+	}
+
+	req.Body = http.MaxBytesReader(w, req.Body, config.UploadMaxSize())
+	mr, err := req.MultipartReader()
+	if err != nil {
+		responseError(w, req, fmt.Errorf("http.serveFormUpload: failed to read multipart form: %v: %w", err, model.ErrInvalidRequest))
+		return
+	}
+
+	var objects []*model.Object
+	var redirectURL string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				responseUploadError(w, req, objects, model.ErrRequestTooLarge)
+				return
+			}
+			responseUploadError(w, req, objects, fmt.Errorf("http.serveFormUpload: failed to read part: %v: %w", err, model.ErrInvalidRequest))
+			return
+		}
+
+		switch part.FormName() {
+		case uploadFormRedirectField:
+			b, err := io.ReadAll(io.LimitReader(part, 2048))
+			if err != nil {
+				responseUploadError(w, req, objects, fmt.Errorf("http.serveFormUpload: failed to read redirect: %v: %w", err, model.ErrInvalidRequest))
+				return
+			}
+			redirectURL = string(b)
+		case uploadFormFileField:
+			if len(part.FileName()) == 0 {
+				continue
+			}
+
+			objectKey := key
+			if len(key) == 0 || strings.HasSuffix(key, "/") {
+				name := path.Base(strings.ReplaceAll(part.FileName(), "\\", "/"))
+				if !isValidObjectName(name) || name == "/" {
+					responseUploadError(w, req, objects, model.ErrInvalidObjectName)
+					return
+				}
+				objectKey = key + name
+			} else if len(objects) > 0 {
+				responseUploadError(w, req, objects, fmt.Errorf("http.serveFormUpload: multiple files for single object: %w", model.ErrInvalidRequest))
+				return
+			}
+
+			contentType := part.Header.Get("Content-Type")
+			if len(contentType) == 0 || contentType == "application/octet-stream" {
+				if ct := mime.TypeByExtension(path.Ext(objectKey)); len(ct) > 0 {
+					contentType = ct
+				}
+			}
+
+			obj, err := model.WriteObject(ctx, storageBucket, objectKey, part, contentType, generationMatch)
+			if err != nil {
+				responseUploadError(w, req, objects, err)
+				return
+			}
+			cachedObjects.invalidate(storageBucket.Object(objectKey))
+			objects = append(objects, obj)
+		}
+	}
+
+	if len(objects) == 0 {
+		responseError(w, req, fmt.Errorf("http.serveFormUpload: no files: %w", model.ErrInvalidRequest))
+		return
+	}
+
+	// redirect back to the page for browser uploads
+	if strings.HasPrefix(redirectURL, "/") && !isProtocolRelative(redirectURL) {
+		http.Redirect(w, req, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	responseJSON(w, http.StatusCreated, objects)
+}
+
+// uploadErrorResponse is the response of the form upload failed after some files were stored.
+type uploadErrorResponse struct {
+	Error   errorpage.Page  `json:"error"`
+	Objects []*model.Object `json:"objects"`
+}
+
+// responseUploadError writes the error of the form upload.
+// The files stored before the error are not rolled back, so they are reported along with the error.
+func responseUploadError(w http.ResponseWriter, req *http.Request, objects []*model.Object, err error) {
+	if len(objects) == 0 {
+		responseError(w, req, err)
+		return
+	}
+
+	status, message := errorStatus(err)
+	responseJSON(w, status, uploadErrorResponse{
+		Error: errorpage.Page{
+			Status:     status,
+			StatusText: http.StatusText(status),
+			Message:    message,
+			RequestID:  req.Header.Get(errorpage.RequestIDHeader),
+		},
+		Objects: objects,
+	})
+}
+
+// isWritable reports whether uploads are allowed for the path.
+func isWritable(p string) bool {
+	return hasAnyPrefix(p, config.WritePathPrefixes())
+}
+
+// hasAnyPrefix reports whether the path is equal to or under any of the prefixes, matching whole path segments.
+func hasAnyPrefix(p string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if len(prefix) == 0 || p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isValidObjectName reports whether the object name has no relative path segments.
+func isValidObjectName(key string) bool {
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "." || seg == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// isSameOrigin reports whether the request is sent from the same origin to prevent cross-site form submissions.
+func isSameOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if len(origin) == 0 {
+		referer := req.Header.Get("Referer")
+		if len(referer) == 0 {
+			// non-browser clients
+			return true
+		}
+		u, err := url.Parse(referer)
+		if err != nil {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	scheme := "http"
+	if util.IsTLS(req) {
+		scheme = "https"
+	}
+	if origin == scheme+"://"+req.Host {
+		return true
+	}
+
+	return len(config.BaseURL()) > 0 && origin == strings.TrimSuffix(config.BaseURL(), "/")
+}
+
+// parseGenerationMatch parses the generation precondition from the ifGenerationMatch query parameter,
+// the x-goog-if-generation-match header, or "If-None-Match: *".
+func parseGenerationMatch(req *http.Request) (*int64, error) {
+	v := req.URL.Query().Get("ifGenerationMatch")
+	if len(v) == 0 {
+		v = req.Header.Get("X-Goog-If-Generation-Match")
+	}
+	if len(v) == 0 {
+		if req.Header.Get("If-None-Match") == "*" {
+			var gen int64
+			return &gen, nil
+		}
+		return nil, nil
+	}
+
+	gen, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || gen < 0 {
+		return nil, fmt.Errorf("http.parseGenerationMatch: invalid generation: %s: %w", v, model.ErrInvalidRequest)
+	}
+	return &gen, nil
+}
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aplulu/gcsproxy/internal/config"
+	"github.com/aplulu/gcsproxy/internal/domain/model"
+	"github.com/aplulu/gcsproxy/internal/interface/http/errorpage"
+)
+
+func TestParseGenerationMatch(t *testing.T) {
+	gen := func(v int64) *int64 { return &v }
+
+	testCases := []struct {
+		name    string
+		query   string
+		header  http.Header
+		want    *int64
+		wantErr error
+	}{{
+		name:   "No precondition",
+		header: http.Header{},
+	}, {
+		name:   "Query parameter",
+		query:  "ifGenerationMatch=123",
+		header: http.Header{},
+		want:   gen(123),
+	}, {
+		name:   "Header",
+		header: http.Header{"X-Goog-If-Generation-Match": {"456"}},
+		want:   gen(456),
+	}, {
+		name:   "If-None-Match",
+		header: http.Header{"If-None-Match": {"*"}},
+		want:   gen(0),
+	}, {
+		name:    "Invalid generation",
+		query:   "ifGenerationMatch=abc",
+		header:  http.Header{},
+		wantErr: model.ErrInvalidRequest,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &http.Request{URL: &url.URL{RawQuery: tc.query}, Header: tc.header}
+
+			got, err := parseGenerationMatch(req)
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIsSameOrigin(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{{
+		name:   "No origin",
+		header: http.Header{},
+		want:   true,
+	}, {
+		name:   "Same origin",
+		header: http.Header{"Origin": {"http://example.com"}},
+		want:   true,
+	}, {
+		name:   "Same origin referer",
+		header: http.Header{"Referer": {"http://example.com/uploads/"}},
+		want:   true,
+	}, {
+		name:   "Cross origin",
+		header: http.Header{"Origin": {"https://evil.example"}},
+	}, {
+		name:   "Cross origin referer",
+		header: http.Header{"Referer": {"https://evil.example/form"}},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &http.Request{Host: "example.com", Header: tc.header}
+
+			assert.Equal(t, tc.want, isSameOrigin(req))
+		})
+	}
+}
+
+func TestHasAnyPrefix(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		prefixes []string
+		want     bool
+	}{
+		{name: "Equal", path: "/uploads", prefixes: []string{"/uploads"}, want: true},
+		{name: "Under prefix", path: "/uploads/a.txt", prefixes: []string{"/uploads"}, want: true},
+		{name: "Prefix with trailing slash", path: "/uploads/a.txt", prefixes: []string{"/uploads/"}, want: true},
+		{name: "Sharing the prefix", path: "/uploads-private/a.txt", prefixes: []string{"/uploads"}, want: false},
+		{name: "Root", path: "/a.txt", prefixes: []string{"/"}, want: true},
+		{name: "Any of prefixes", path: "/b/a.txt", prefixes: []string{"/a", "/b"}, want: true},
+		{name: "No prefixes", path: "/a.txt", prefixes: nil, want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, hasAnyPrefix(tc.path, tc.prefixes))
+		})
+	}
+}
+
+func TestServeFormUpload_PartialFailure(t *testing.T) {
+	t.Setenv("WRITE_PATH_PREFIXES", "/uploads")
+	assert.NoError(t, config.LoadConf())
+	gcs, bucket := newFakeGCS(t)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, name := range []string{"a.txt", ".."} {
+		fw, err := mw.CreateFormFile("file", name)
+		assert.NoError(t, err)
+		_, err = io.WriteString(fw, "contents")
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/uploads/", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	serveFormUpload(w, req, bucket, "uploads/")
+
+	// the stored file is reported along with the error
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var res struct {
+		Error   errorpage.Page  `json:"error"`
+		Objects []*model.Object `json:"objects"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, http.StatusBadRequest, res.Error.Status)
+	if assert.Len(t, res.Objects, 1) {
+		assert.Equal(t, "uploads/a.txt", res.Objects[0].Name)
+	}
+	assert.NotNil(t, gcs.get("uploads/a.txt"))
+}
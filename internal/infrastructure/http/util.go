@@ -1,7 +1,9 @@
 package http
 
 import (
+	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -9,13 +11,18 @@ import (
 
 	"github.com/aplulu/gcsproxy/internal/config"
 	"github.com/aplulu/gcsproxy/internal/domain/model"
+	"github.com/aplulu/gcsproxy/internal/interface/http/errorpage"
 )
 
 func writeHeaders(w http.ResponseWriter, attrs *storage.ObjectAttrs, chunked bool) {
 	writeStringHeader(w, "Last-Modified", attrs.Updated.Format(http.TimeFormat))
+	writeStringHeader(w, "ETag", objectETag(attrs))
 	writeStringHeader(w, "Content-Type", attrs.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
 	writeStringHeader(w, "Content-Disposition", attrs.ContentDisposition)
 	writeStringHeader(w, "Content-Encoding", attrs.ContentEncoding)
+	writeStringHeader(w, "Content-Language", attrs.ContentLanguage)
+	writeMetadataHeaders(w, attrs)
 
 	if chunked {
 		writeStringHeader(w, "Transfer-Encoding", "chunked")
@@ -23,6 +30,10 @@ func writeHeaders(w http.ResponseWriter, attrs *storage.ObjectAttrs, chunked boo
 		writeInt64Header(w, "Content-Length", attrs.Size)
 	}
 
+	writeCacheControlHeader(w, attrs)
+}
+
+func writeCacheControlHeader(w http.ResponseWriter, attrs *storage.ObjectAttrs) {
 	// do not cache if authentication is enabled
 	if config.AuthType() == "oidc" || config.AuthType() == "basic" {
 		writeStringHeader(w, "Cache-Control", "private, max-age=60")
@@ -31,14 +42,39 @@ func writeHeaders(w http.ResponseWriter, attrs *storage.ObjectAttrs, chunked boo
 	}
 }
 
-func responseError(w http.ResponseWriter, err error) {
+func responseError(w http.ResponseWriter, req *http.Request, err error) {
+	status, message := errorStatus(err)
+	errorpage.Write(w, req, status, message)
+}
+
+// errorStatus returns the status code and the message of the error response.
+func errorStatus(err error) (int, string) {
 	switch {
-	case errors.Is(err, storage.ErrObjectNotExist):
-		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, storage.ErrObjectNotExist), errors.Is(err, storage.ErrBucketNotExist):
+		return http.StatusNotFound, "not found"
+	case errors.Is(err, model.ErrInvalidObjectName), errors.Is(err, model.ErrInvalidRequest):
+		return http.StatusBadRequest, "bad request"
+	case errors.Is(err, model.ErrForbidden):
+		return http.StatusForbidden, "forbidden"
+	case errors.Is(err, model.ErrRequestTooLarge):
+		return http.StatusRequestEntityTooLarge, "request entity too large"
+	case errors.Is(err, model.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed, "precondition failed"
+	case errors.Is(err, model.ErrRangeNotSatisfiable):
+		return http.StatusRequestedRangeNotSatisfiable, "range not satisfiable"
 	case errors.Is(err, model.ErrStreamingUnsupported):
-		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return http.StatusInternalServerError, "streaming unsupported"
 	default:
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return http.StatusInternalServerError, "internal server error"
+	}
+}
+
+func responseJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("http.responseJSON: failed to encode response: %v\n", err)
 	}
 }
 
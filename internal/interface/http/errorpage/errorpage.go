@@ -0,0 +1,156 @@
+// Package errorpage writes the error responses as HTML pages, or as JSON for API clients.
+package errorpage
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RequestIDHeader is the request header of the request ID shown on the error pages.
+const RequestIDHeader = "X-Request-Id"
+
+//go:embed error.html
+var defaultPage string
+
+var defaultTemplate = template.Must(template.New("error").Parse(defaultPage))
+
+// Page is the data of the error page templates.
+type Page struct {
+	Status     int    `json:"code"`
+	StatusText string `json:"status"`
+	Message    string `json:"message"`
+	RequestID  string `json:"requestId,omitempty"`
+	// LoginURL is the URL to sign in again, set only for 401 Unauthorized and 403 Forbidden.
+	LoginURL string `json:"loginUrl,omitempty"`
+}
+
+// Loader returns the template of the error page for the request and the status, or false to render the default page.
+type Loader func(r *http.Request, status int) (*template.Template, bool)
+
+// Config is the configuration of the error pages.
+type Config struct {
+	// Loader loads the custom error pages. Nil renders the default page for all statuses.
+	Loader Loader
+	// LoginURL returns the URL to sign in again for the request. Nil omits the login link.
+	LoginURL func(r *http.Request) string
+	// JSONPathPrefixes are the path prefixes that always respond with JSON.
+	JSONPathPrefixes []string
+}
+
+var conf Config
+
+// Configure sets the configuration of the error pages. It must be called before serving requests.
+func Configure(c Config) {
+	conf = c
+}
+
+// Write writes the error response with the status and the message.
+func Write(w http.ResponseWriter, r *http.Request, status int, message string) {
+	page := Page{
+		Status:     status,
+		StatusText: http.StatusText(status),
+		Message:    message,
+		RequestID:  r.Header.Get(RequestIDHeader),
+	}
+	if conf.LoginURL != nil && (status == http.StatusUnauthorized || status == http.StatusForbidden) {
+		page.LoginURL = conf.LoginURL(r)
+	}
+
+	// the headers of the object may have been written before the error
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	if wantsJSON(r) {
+		h.Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(struct {
+			Error Page `json:"error"`
+		}{page}); err != nil {
+			log.Printf("errorpage.Write: failed to encode response: %v\n", err)
+		}
+		return
+	}
+
+	tmpl := defaultTemplate
+	if conf.Loader != nil {
+		if t, ok := conf.Loader(r, status); ok {
+			tmpl = t
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, page); err != nil {
+		log.Printf("errorpage.Write: failed to render error page: %v\n", err)
+		buf.Reset()
+		_ = defaultTemplate.Execute(&buf, page)
+	}
+
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// wantsJSON reports whether the client prefers JSON to HTML.
+// Equal qualities are resolved by the more specific media range, so that "application/json, */*" prefers JSON.
+func wantsJSON(r *http.Request) bool {
+	for _, p := range conf.JSONPathPrefixes {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+
+	accept := r.Header.Get("Accept")
+	jsonQ, jsonSpecificity := acceptQuality(accept, "application/json")
+	htmlQ, htmlSpecificity := acceptQuality(accept, "text/html")
+	if jsonQ != htmlQ {
+		return jsonQ > htmlQ
+	}
+	return jsonQ > 0 && jsonSpecificity > htmlSpecificity
+}
+
+// acceptQuality returns the quality and the specificity of the most specific media range in the Accept header matching the media type.
+// The specificity is 2 for the exact type, 1 for "type/*" and 0 for "*/*".
+func acceptQuality(accept string, mediaType string) (float64, int) {
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, -1
+	for _, v := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+
+		var s int
+		switch mediaRange {
+		case mediaType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s <= specificity {
+			continue
+		}
+
+		specificity = s
+		q = 1
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+	}
+	return q, specificity
+}
@@ -0,0 +1,124 @@
+package errorpage
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	t.Cleanup(func() {
+		Configure(Config{})
+	})
+	Configure(Config{
+		Loader: func(r *http.Request, status int) (*template.Template, bool) {
+			if status != http.StatusNotFound {
+				return nil, false
+			}
+			return template.Must(template.New("404").Parse("<p>{{.Message}} ({{.RequestID}})</p>")), true
+		},
+		LoginURL: func(r *http.Request) string {
+			return "/_gcsproxy/oidc/login?redirect=" + r.URL.Path
+		},
+		JSONPathPrefixes: []string{"/_gcsproxy/api/"},
+	})
+
+	t.Run("HTML", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/private/a.txt", nil)
+		req.Header.Set(RequestIDHeader, "abc123")
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Content-Length", "100")
+
+		Write(rec, req, http.StatusForbidden, "<forbidden>")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+		assert.Empty(t, rec.Header().Get("Content-Length"))
+		assert.Contains(t, rec.Body.String(), "403 Forbidden")
+		assert.Contains(t, rec.Body.String(), "&lt;forbidden&gt;")
+		assert.Contains(t, rec.Body.String(), "abc123")
+		assert.Contains(t, rec.Body.String(), `href="/_gcsproxy/oidc/login?redirect=/private/a.txt"`)
+	})
+
+	t.Run("No login link for server errors", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+		rec := httptest.NewRecorder()
+
+		Write(rec, req, http.StatusInternalServerError, "internal server error")
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "/_gcsproxy/oidc/login")
+	})
+
+	t.Run("Custom page", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		req.Header.Set(RequestIDHeader, "abc123")
+		rec := httptest.NewRecorder()
+
+		Write(rec, req, http.StatusNotFound, "not found")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "<p>not found (abc123)</p>", rec.Body.String())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/private/a.txt", nil)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set(RequestIDHeader, "abc123")
+		rec := httptest.NewRecorder()
+
+		Write(rec, req, http.StatusForbidden, "forbidden")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+		var body struct {
+			Error Page `json:"error"`
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, Page{
+			Status:     http.StatusForbidden,
+			StatusText: "Forbidden",
+			Message:    "forbidden",
+			RequestID:  "abc123",
+			LoginURL:   "/_gcsproxy/oidc/login?redirect=/private/a.txt",
+		}, body.Error)
+	})
+}
+
+func TestWantsJSON(t *testing.T) {
+	t.Cleanup(func() {
+		Configure(Config{})
+	})
+	Configure(Config{JSONPathPrefixes: []string{"/_gcsproxy/api/"}})
+
+	testCases := []struct {
+		name   string
+		path   string
+		accept string
+		want   bool
+	}{
+		{name: "No Accept", path: "/a.txt", accept: "", want: false},
+		{name: "Any", path: "/a.txt", accept: "*/*", want: false},
+		{name: "Browser", path: "/a.txt", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: false},
+		{name: "JSON", path: "/a.txt", accept: "application/json", want: true},
+		{name: "JSON and any", path: "/a.txt", accept: "application/json, text/plain, */*", want: true},
+		{name: "HTML preferred", path: "/a.txt", accept: "application/json;q=0.5, text/html", want: false},
+		{name: "JSON preferred", path: "/a.txt", accept: "text/html;q=0.5, application/*", want: true},
+		{name: "API path", path: "/_gcsproxy/api/v1/objects", accept: "text/html", want: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if len(tc.accept) > 0 {
+				req.Header.Set("Accept", tc.accept)
+			}
+			assert.Equal(t, tc.want, wantsJSON(req))
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloud.google.com/go/storage"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/aplulu/gcsproxy/internal/domain/model"
+	"github.com/aplulu/gcsproxy/internal/interface/http/errorpage"
+)
+
+type ObjectController interface {
+	List(w http.ResponseWriter, r *http.Request)
+}
+
+// ListScope is the bucket and the object prefix to list objects for a URL path prefix.
+type ListScope struct {
+	Bucket *storage.BucketHandle
+	// Root is the object prefix of the site, which is stripped from the names.
+	Root string
+	// PathPrefix is the URL path prefix of the site (e.g. "assets/"), which is prepended to the names.
+	PathPrefix string
+	// Prefix is the listed prefix relative to Root.
+	Prefix string
+}
+
+// BucketResolver returns the scope to list objects for the request and the URL path prefix.
+type BucketResolver func(r *http.Request, prefix string) (*ListScope, bool)
+
+type objectController struct {
+	resolveBucket BucketResolver
+}
+
+// List is the handler for the object listing route.
+func (c *objectController) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	scope, ok := c.resolveBucket(r, query.Get("prefix"))
+	if !ok {
+		errorpage.Write(w, r, http.StatusNotFound, "not found")
+		return
+	}
+
+	var pageSize int
+	if ps := query.Get("pageSize"); len(ps) > 0 {
+		var err error
+		pageSize, err = strconv.Atoi(ps)
+		if err != nil {
+			errorpage.Write(w, r, http.StatusBadRequest, "invalid pageSize")
+			return
+		}
+	}
+
+	list, err := model.ListObjects(ctx, scope.Bucket, scope.Root, scope.Prefix, query.Get("delimiter"), query.Get("pageToken"), pageSize)
+	if err != nil {
+		responseError(w, r, err)
+		return
+	}
+	for _, o := range list.Objects {
+		o.Name = scope.PathPrefix + o.Name
+	}
+	for i, p := range list.Prefixes {
+		list.Prefixes[i] = scope.PathPrefix + p
+	}
+
+	responseJSON(w, http.StatusOK, list)
+}
+
+func NewObjectController(resolveBucket BucketResolver) ObjectController {
+	return &objectController{
+		resolveBucket: resolveBucket,
+	}
+}
+
+// RegisterAPI registers the JSON API routes.
+func RegisterAPI(mux *chi.Mux, resolveBucket BucketResolver) {
+	controller := NewObjectController(resolveBucket)
+
+	mux.Get("/objects", controller.List)
+}
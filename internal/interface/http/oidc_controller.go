@@ -32,14 +32,14 @@ func (c *oidcController) Login(w http.ResponseWriter, r *http.Request) {
 	// Get OIDC config
 	oc, err := model.GetOIDCConfig(ctx)
 	if err != nil {
-		responseError(w, err)
+		responseError(w, r, err)
 		return
 	}
 
 	// Create auth session
 	sessStr, sess, err := model.NewOIDCSession(r.URL.Query().Get("redirect"))
 	if err != nil {
-		responseError(w, err)
+		responseError(w, r, err)
 		return
 	}
 
@@ -69,30 +69,30 @@ func (c *oidcController) Callback(w http.ResponseWriter, r *http.Request) {
 	// Get OIDC session
 	sessCookie, err := r.Cookie(oidcSessionCookieName)
 	if err != nil {
-		responseError(w, err)
+		responseError(w, r, err)
 		return
 	}
 	sess, err := model.ParseOIDCSession(sessCookie.Value)
 	if err != nil {
-		responseError(w, err)
+		responseError(w, r, err)
 		return
 	}
 	if sess.State != r.URL.Query().Get("state") {
-		responseError(w, model.ErrInvalidState)
+		responseError(w, r, model.ErrInvalidState)
 		return
 	}
 
 	// Exchange code for token
 	token, err := model.ExchangeOIDCToken(ctx, r.URL.Query().Get("code"))
 	if err != nil {
-		responseError(w, err)
+		responseError(w, r, err)
 		return
 	}
 
 	// Create Auth session
 	sessToken, exp, err := model.CreateAuthSession(token.Sub)
 	if err != nil {
-		responseError(w, err)
+		responseError(w, r, err)
 		return
 	}
 
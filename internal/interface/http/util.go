@@ -1,20 +1,34 @@
 package http
 
 import (
+	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 
 	"github.com/aplulu/gcsproxy/internal/domain/model"
+	"github.com/aplulu/gcsproxy/internal/interface/http/errorpage"
 )
 
-func responseError(w http.ResponseWriter, err error) {
+func responseError(w http.ResponseWriter, r *http.Request, err error) {
 	switch {
 	case errors.Is(err, model.ErrInvalidRedirectURL):
-	case errors.Is(err, model.ErrInvalidState):
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		errorpage.Write(w, r, http.StatusBadRequest, "invalid redirect URL")
+	case errors.Is(err, model.ErrInvalidState), errors.Is(err, http.ErrNoCookie):
+		errorpage.Write(w, r, http.StatusBadRequest, "The sign-in session has expired. Please sign in again.")
 	case errors.Is(err, model.ErrInvalidHostedDomain):
-		http.Error(w, "Access with this Google account is not allowed", http.StatusForbidden)
+		errorpage.Write(w, r, http.StatusForbidden, "Access with this Google account is not allowed")
 	default:
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Printf("http.responseError: %v\n", err)
+		errorpage.Write(w, r, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+func responseJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("http.responseJSON: failed to encode response: %v\n", err)
 	}
 }
@@ -0,0 +1,31 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GlobToRegexp compiles the glob pattern of the URL path into the regular expression matching the whole path.
+// "*" matches any characters except "/", "**" matches any characters including "/", and "?" matches a character except "/".
+func GlobToRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pattern string
+		path    string
+		want    bool
+	}{{
+		name:    "Exact",
+		pattern: "/index.html",
+		path:    "/index.html",
+		want:    true,
+	}, {
+		name:    "Dot is literal",
+		pattern: "/index.html",
+		path:    "/index_html",
+	}, {
+		name:    "Star",
+		pattern: "/assets/*.js",
+		path:    "/assets/app.js",
+		want:    true,
+	}, {
+		name:    "Star does not match slash",
+		pattern: "/assets/*.js",
+		path:    "/assets/vendor/app.js",
+	}, {
+		name:    "Double star",
+		pattern: "/assets/**",
+		path:    "/assets/vendor/app.js",
+		want:    true,
+	}, {
+		name:    "Question mark",
+		pattern: "/v?/",
+		path:    "/v1/",
+		want:    true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			re, err := GlobToRegexp(tc.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, re.MatchString(tc.path))
+		})
+	}
+}
@@ -1,7 +1,51 @@
 package util
 
-import "net/http"
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
 
 func IsTLS(r *http.Request) bool {
 	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
 }
+
+// AcceptsEncoding reports whether the Accept-Encoding header allows the content coding.
+func AcceptsEncoding(r *http.Request, encoding string) bool {
+	wildcard := false
+	for _, v := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(v, ",") {
+			coding, q := parseQualityValue(part)
+			if coding == "" {
+				continue
+			}
+			if strings.EqualFold(coding, encoding) {
+				return q > 0
+			}
+			if coding == "*" {
+				wildcard = q > 0
+			}
+		}
+	}
+	return wildcard
+}
+
+// parseQualityValue parses the value with the optional quality value (e.g. "gzip;q=0.8").
+func parseQualityValue(s string) (string, float64) {
+	value, params, _ := strings.Cut(s, ";")
+	value = strings.TrimSpace(value)
+
+	q := 1.0
+	for _, param := range strings.Split(params, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(k), "q") {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return value, 0
+		}
+		q = f
+	}
+	return value, q
+}
@@ -54,3 +54,51 @@ func TestIsTLS(t *testing.T) {
 		})
 	}
 }
+
+func TestAcceptsEncoding(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   []string
+		encoding string
+		want     bool
+	}{{
+		name:     "Accepts #1",
+		header:   []string{"gzip, deflate, br"},
+		encoding: "br",
+		want:     true,
+	}, {
+		name:     "Accepts #2",
+		header:   []string{"deflate", "GZIP;q=0.5"},
+		encoding: "gzip",
+		want:     true,
+	}, {
+		name:     "Accepts #3",
+		header:   []string{"*"},
+		encoding: "zstd",
+		want:     true,
+	}, {
+		name:     "Does not accept #1",
+		header:   []string{"gzip"},
+		encoding: "br",
+	}, {
+		name:     "Does not accept #2",
+		header:   []string{"gzip;q=0, *"},
+		encoding: "gzip",
+	}, {
+		name:     "Does not accept #3",
+		encoding: "gzip",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &http.Request{Header: http.Header{}}
+			for _, v := range tc.header {
+				r.Header.Add("Accept-Encoding", v)
+			}
+
+			got := AcceptsEncoding(r, tc.encoding)
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
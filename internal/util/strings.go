@@ -5,6 +5,7 @@ import (
 	"math"
 	"math/big"
 	"math/rand"
+	"strings"
 )
 
 const randomChars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -23,3 +24,13 @@ func RandomString(n int) string {
 	}
 	return string(b)
 }
+
+// ContainsFold reports whether the values contain s, ignoring the case and the surrounding spaces of the values.
+func ContainsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
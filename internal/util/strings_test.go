@@ -23,3 +23,35 @@ func TestRandomString(t *testing.T) {
 		})
 	}
 }
+
+func TestContainsFold(t *testing.T) {
+	testCases := []struct {
+		name   string
+		values []string
+		arg    string
+		want   bool
+	}{{
+		name:   "Same case",
+		values: []string{"GET", "POST"},
+		arg:    "POST",
+		want:   true,
+	}, {
+		name:   "Different case and spaces",
+		values: []string{"jp", " Us "},
+		arg:    "US",
+		want:   true,
+	}, {
+		name:   "Missing",
+		values: []string{"GET"},
+		arg:    "DELETE",
+		want:   false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ContainsFold(tc.values, tc.arg); got != tc.want {
+				t.Errorf("ContainsFold() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package diskcache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	partialFileSuffix = ".part"
+)
+
+// Cache is a file cache in the directory bounded by the total size of the files.
+// Files are written to partial files and renamed when committed, so that interrupted writes never become entries.
+type Cache struct {
+	// OnEvict is called when the entry is evicted to free the space.
+	OnEvict func(key string)
+
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+	size    int64
+	ll      *list.List
+	items   map[string]*list.Element
+	writing map[string]bool
+}
+
+type entry struct {
+	name string
+	key  string
+	size int64
+}
+
+// New creates a Cache in the directory with the maximum total size.
+// Partial files left by a crash are removed and the committed files are indexed by their modification time.
+func New(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("diskcache.New: failed to create directory: %w", err)
+	}
+
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+		writing: make(map[string]bool),
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("diskcache.New: failed to read directory: %w", err)
+	}
+
+	var files []os.FileInfo
+	for _, de := range dirEntries {
+		if de.IsDir() {
+			continue
+		}
+		// files other than the cache files are never indexed nor removed, the directory may be shared
+		if isPartialFileName(de.Name()) {
+			_ = os.Remove(filepath.Join(dir, de.Name()))
+			continue
+		}
+		if !isFileName(de.Name()) {
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, fi)
+	}
+
+	// the most recently used file first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+	for _, fi := range files {
+		c.items[fi.Name()] = c.ll.PushBack(&entry{name: fi.Name(), size: fi.Size()})
+		c.size += fi.Size()
+	}
+	c.evict()
+
+	return c, nil
+}
+
+// fileName returns the file name of the key.
+func fileName(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// isFileName reports whether the name is a file name returned by fileName.
+func isFileName(name string) bool {
+	if len(name) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range name {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// isPartialFileName reports whether the name is a partial file name created by Create.
+func isPartialFileName(name string) bool {
+	if !strings.HasSuffix(name, partialFileSuffix) {
+		return false
+	}
+	prefix, _, ok := strings.Cut(name, ".")
+	return ok && isFileName(prefix)
+}
+
+// Open opens the committed file of the key.
+func (c *Cache) Open(key string) (*os.File, bool) {
+	name := fileName(key)
+
+	c.mu.Lock()
+	el, ok := c.items[name]
+	if ok {
+		c.ll.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	p := filepath.Join(c.dir, name)
+	f, err := os.Open(p)
+	if err != nil {
+		c.mu.Lock()
+		if el, ok := c.items[name]; ok {
+			c.removeElement(el)
+		}
+		c.mu.Unlock()
+		return nil, false
+	}
+
+	// keep the recency across restarts
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+
+	return f, true
+}
+
+// Create creates the Writer of the key.
+// It returns false if the key is already committed or being written.
+func (c *Cache) Create(key string) (*Writer, bool) {
+	name := fileName(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[name]; ok || c.writing[name] {
+		return nil, false
+	}
+
+	f, err := os.CreateTemp(c.dir, name+".*"+partialFileSuffix)
+	if err != nil {
+		return nil, false
+	}
+	c.writing[name] = true
+
+	return &Writer{
+		c:      c,
+		f:      f,
+		name:   name,
+		key:    key,
+		notify: make(chan struct{}),
+	}, true
+}
+
+// Size returns the total size of the committed files.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+// evict removes the least recently used files until the total size fits.
+func (c *Cache) evict() {
+	for c.size > c.maxSize {
+		last := c.ll.Back()
+		if last == nil {
+			break
+		}
+		e := last.Value.(*entry)
+		c.removeElement(last)
+		if c.OnEvict != nil {
+			c.OnEvict(e.key)
+		}
+	}
+}
+
+func (c *Cache) removeElement(el *list.Element) {
+	e := c.ll.Remove(el).(*entry)
+	delete(c.items, e.name)
+	c.size -= e.size
+	// open readers keep reading the removed file
+	_ = os.Remove(filepath.Join(c.dir, e.name))
+}
+
+// Writer writes the partial file of the key.
+type Writer struct {
+	c    *Cache
+	f    *os.File
+	name string
+	key  string
+
+	mu     sync.Mutex
+	size   int64
+	notify chan struct{}
+	done   bool
+	err    error
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	n, err := w.f.Write(b)
+
+	w.mu.Lock()
+	w.size += int64(n)
+	// wake up the readers following the file
+	close(w.notify)
+	w.notify = make(chan struct{})
+	w.mu.Unlock()
+
+	return n, err
+}
+
+// Commit syncs and renames the partial file to add the entry, evicting the least recently used files if the cache is full.
+func (w *Writer) Commit() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.done {
+		return w.err
+	}
+	// too large to be cached, the readers following the file still read it to the end
+	if w.size > w.c.maxSize {
+		w.abort(nil)
+		return nil
+	}
+
+	if err := w.f.Sync(); err != nil {
+		w.abort(err)
+		return fmt.Errorf("diskcache.Writer.Commit: failed to sync file: %w", err)
+	}
+	if err := w.f.Close(); err != nil {
+		w.abort(err)
+		return fmt.Errorf("diskcache.Writer.Commit: failed to close file: %w", err)
+	}
+
+	w.c.mu.Lock()
+	defer w.c.mu.Unlock()
+
+	delete(w.c.writing, w.name)
+	if err := os.Rename(w.f.Name(), filepath.Join(w.c.dir, w.name)); err != nil {
+		_ = os.Remove(w.f.Name())
+		w.finish(err)
+		return fmt.Errorf("diskcache.Writer.Commit: failed to rename file: %w", err)
+	}
+
+	w.c.items[w.name] = w.c.ll.PushFront(&entry{name: w.name, key: w.key, size: w.size})
+	w.c.size += w.size
+	w.c.evict()
+	w.finish(nil)
+
+	return nil
+}
+
+// Abort removes the partial file.
+func (w *Writer) Abort() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.done {
+		return
+	}
+	w.abort(ErrAborted)
+}
+
+func (w *Writer) abort(err error) {
+	_ = w.f.Close()
+	_ = os.Remove(w.f.Name())
+
+	w.c.mu.Lock()
+	delete(w.c.writing, w.name)
+	w.c.mu.Unlock()
+
+	w.finish(err)
+}
+
+func (w *Writer) finish(err error) {
+	w.done = true
+	w.err = err
+	close(w.notify)
+}
+
+// NewReader returns the reader following the file while written.
+// The reader fails if the writer is aborted.
+// Once committed, the reader opens the cached file.
+func (w *Writer) NewReader(ctx context.Context) (*Reader, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p := w.f.Name()
+	if w.done {
+		if w.err != nil {
+			return nil, w.err
+		}
+		p = filepath.Join(w.c.dir, w.name)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("diskcache.Writer.NewReader: failed to open file: %w", err)
+	}
+
+	return &Reader{
+		ctx: ctx,
+		w:   w,
+		f:   f,
+	}, nil
+}
+
+// Reader reads the file of the Writer, waiting for more data until the writer finishes.
+type Reader struct {
+	ctx context.Context
+	w   *Writer
+	f   *os.File
+	off int64
+}
+
+func (r *Reader) Read(b []byte) (int, error) {
+	for {
+		r.w.mu.Lock()
+		avail := r.w.size - r.off
+		done, err, notify := r.w.done, r.w.err, r.w.notify
+		r.w.mu.Unlock()
+
+		if avail > 0 {
+			if int64(len(b)) > avail {
+				b = b[:avail]
+			}
+			n, err := r.f.ReadAt(b, r.off)
+			r.off += int64(n)
+			if err == io.EOF && n > 0 {
+				err = nil
+			}
+			return n, err
+		}
+		if done {
+			if err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+
+		select {
+		case <-notify:
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
+	}
+}
+
+func (r *Reader) Close() error {
+	return r.f.Close()
+}
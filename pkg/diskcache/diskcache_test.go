@@ -0,0 +1,176 @@
+package diskcache
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func write(t *testing.T, c *Cache, key string, content string) {
+	w, ok := c.Create(key)
+	assert.True(t, ok)
+	_, err := io.WriteString(w, content)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Commit())
+}
+
+func read(t *testing.T, c *Cache, key string) (string, bool) {
+	f, ok := c.Open(key)
+	if !ok {
+		return "", false
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	return string(b), true
+}
+
+func TestCache_Evict(t *testing.T) {
+	c, err := New(t.TempDir(), 10)
+	assert.NoError(t, err)
+
+	write(t, c, "a", "aaaa")
+	write(t, c, "b", "bbbb")
+	got, ok := read(t, c, "a")
+	assert.True(t, ok)
+	assert.Equal(t, "aaaa", got)
+
+	// b is the least recently used
+	write(t, c, "c", "cccc")
+	_, ok = read(t, c, "b")
+	assert.False(t, ok)
+	assert.Equal(t, int64(8), c.Size())
+
+	// too large to be cached
+	write(t, c, "d", "ddddddddddd")
+	_, ok = read(t, c, "d")
+	assert.False(t, ok)
+}
+
+func TestCache_Create(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 10)
+	assert.NoError(t, err)
+
+	w, ok := c.Create("a")
+	assert.True(t, ok)
+
+	// the key is being written
+	_, ok = c.Create("a")
+	assert.False(t, ok)
+
+	_, err = io.WriteString(w, "aaaa")
+	assert.NoError(t, err)
+	w.Abort()
+
+	_, ok = read(t, c, "a")
+	assert.False(t, ok)
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	write(t, c, "a", "aaaa")
+	_, ok = c.Create("a")
+	assert.False(t, ok)
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 10)
+	assert.NoError(t, err)
+	write(t, c, "a", "aaaa")
+
+	// the partial file left by a crash
+	_, ok := c.Create("b")
+	assert.True(t, ok)
+
+	c, err = New(dir, 10)
+	assert.NoError(t, err)
+
+	got, ok := read(t, c, "a")
+	assert.True(t, ok)
+	assert.Equal(t, "aaaa", got)
+	assert.Equal(t, int64(4), c.Size())
+
+	partials, err := filepath.Glob(filepath.Join(dir, "*"+partialFileSuffix))
+	assert.NoError(t, err)
+	assert.Empty(t, partials)
+}
+
+func TestNew_ForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"foreign.txt", "foreign.part", "notes.1.part"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), make([]byte, 2000), 0o600))
+	}
+
+	c, err := New(dir, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), c.Size())
+
+	for _, name := range []string{"foreign.txt", "foreign.part", "notes.1.part"} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestWriter_NewReader(t *testing.T) {
+	c, err := New(t.TempDir(), 100)
+	assert.NoError(t, err)
+
+	w, ok := c.Create("a")
+	assert.True(t, ok)
+
+	r, err := w.NewReader(context.Background())
+	assert.NoError(t, err)
+	defer r.Close()
+
+	got := make(chan string)
+	go func() {
+		b, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		got <- string(b)
+	}()
+
+	for _, s := range []string{"aaaa", "bbbb", "cccc"} {
+		_, err := io.WriteString(w, s)
+		assert.NoError(t, err)
+		time.Sleep(time.Millisecond)
+	}
+	assert.NoError(t, w.Commit())
+	assert.Equal(t, "aaaabbbbcccc", <-got)
+
+	// opens the committed file
+	r2, err := w.NewReader(context.Background())
+	assert.NoError(t, err)
+	b, err := io.ReadAll(r2)
+	assert.NoError(t, err)
+	assert.Equal(t, "aaaabbbbcccc", string(b))
+	assert.NoError(t, r2.Close())
+}
+
+func TestWriter_Abort(t *testing.T) {
+	c, err := New(t.TempDir(), 100)
+	assert.NoError(t, err)
+
+	w, ok := c.Create("a")
+	assert.True(t, ok)
+	r, err := w.NewReader(context.Background())
+	assert.NoError(t, err)
+	defer r.Close()
+
+	_, err = io.WriteString(w, "aaaa")
+	assert.NoError(t, err)
+	w.Abort()
+
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, ErrAborted)
+
+	_, err = w.NewReader(context.Background())
+	assert.ErrorIs(t, err, ErrAborted)
+}
@@ -0,0 +1,7 @@
+package diskcache
+
+import "errors"
+
+var (
+	ErrAborted = errors.New("aborted")
+)
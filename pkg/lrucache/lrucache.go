@@ -0,0 +1,125 @@
+package lrucache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache is a thread-safe LRU cache bounded by the total size of the entries.
+// Entries expire after the TTL.
+type Cache struct {
+	mu      sync.Mutex
+	maxSize int64
+	ttl     time.Duration
+	size    int64
+	ll      *list.List
+	items   map[string]*list.Element
+	now     func() time.Time
+	OnEvict func(key string, value interface{})
+}
+
+type entry struct {
+	key     string
+	value   interface{}
+	size    int64
+	expires time.Time
+}
+
+// New creates a Cache with the maximum total size and the TTL of the entries.
+func New(maxSize int64, ttl time.Duration) *Cache {
+	return &Cache{
+		maxSize: maxSize,
+		ttl:     ttl,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// Get returns the value of the key if it exists and has not expired.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if !c.now().Before(e.expires) {
+		c.removeElement(el)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	return e.value, true
+}
+
+// Set adds the value with its size, evicting the least recently used entries if the cache is full.
+// Values larger than the maximum size are not added.
+func (c *Cache) Set(key string, value interface{}, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	if size > c.maxSize {
+		return
+	}
+
+	el := c.ll.PushFront(&entry{
+		key:     key,
+		value:   value,
+		size:    size,
+		expires: c.now().Add(c.ttl),
+	})
+	c.items[key] = el
+	c.size += size
+
+	for c.size > c.maxSize {
+		last := c.ll.Back()
+		if last == nil {
+			break
+		}
+		c.removeElement(last)
+		if c.OnEvict != nil {
+			e := last.Value.(*entry)
+			c.OnEvict(e.key, e.value)
+		}
+	}
+}
+
+// Delete removes the key.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// Len returns the number of entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+// Size returns the total size of the entries.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+func (c *Cache) removeElement(el *list.Element) {
+	e := c.ll.Remove(el).(*entry)
+	delete(c.items, e.key)
+	c.size -= e.size
+}
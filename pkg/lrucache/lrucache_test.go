@@ -0,0 +1,65 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_Evict(t *testing.T) {
+	c := New(10, time.Minute)
+	var evicted []string
+	c.OnEvict = func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	}
+
+	c.Set("a", 1, 4)
+	c.Set("b", 2, 4)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	// b is the least recently used
+	c.Set("c", 3, 4)
+	assert.Equal(t, []string{"b"}, evicted)
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, int64(8), c.Size())
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+
+	// too large to be cached
+	c.Set("d", 4, 11)
+	_, ok = c.Get("d")
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestCache_Expire(t *testing.T) {
+	now := time.Now()
+	c := New(10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1, 1)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	now = now.Add(time.Minute)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, int64(0), c.Size())
+}
+
+func TestCache_Delete(t *testing.T) {
+	c := New(10, time.Minute)
+
+	c.Set("a", 1, 1)
+	c.Set("a", 2, 2)
+	assert.Equal(t, int64(2), c.Size())
+
+	c.Delete("a")
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
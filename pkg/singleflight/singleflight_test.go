@@ -0,0 +1,121 @@
+package singleflight
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitForWaiters waits until the call of the key has n waiters.
+func waitForWaiters(g *Group, key string, n int) {
+	for {
+		g.mu.Lock()
+		c, ok := g.calls[key]
+		done := ok && c.waiters == n
+		g.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGroup_Do(t *testing.T) {
+	var g Group
+	var calls int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := g.Do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return "value", nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "value", v)
+		}()
+	}
+
+	waitForWaiters(&g, "key", 10)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestGroup_Do_Cancel(t *testing.T) {
+	var g Group
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	release := make(chan struct{})
+
+	fn := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			close(canceled)
+			return nil, ctx.Err()
+		case <-release:
+			return "value", nil
+		}
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		_, err := g.Do(ctx1, "key", fn)
+		errCh <- err
+	}()
+	<-started
+
+	resCh := make(chan interface{})
+	go func() {
+		v, _ := g.Do(context.Background(), "key", fn)
+		resCh <- v
+	}()
+	waitForWaiters(&g, "key", 2)
+
+	// the other caller keeps waiting for the shared call
+	cancel1()
+	assert.ErrorIs(t, <-errCh, context.Canceled)
+	select {
+	case <-canceled:
+		t.Fatal("the call must not be canceled while another caller waits")
+	default:
+	}
+
+	close(release)
+	assert.Equal(t, "value", <-resCh)
+}
+
+func TestGroup_Do_CancelAll(t *testing.T) {
+	var g Group
+	canceled := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := g.Do(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the call must be canceled once all callers have given up")
+	}
+}